@@ -0,0 +1,178 @@
+// Package admin provides an HTTP handler to inspect and change logger levels at runtime.
+//
+//     http.Handle("/debug/log15", admin.NewHandler())
+//
+// The handler serves the following requests (the root logger has an empty name):
+//
+//     GET    /debug/log15                              lists all registered loggers
+//     GET    /debug/log15?name=db                      shows the "db" logger
+//     PUT    /debug/log15?name=db&level=debug&ttl=10m  sets the "db" level for 10 minutes
+//     DELETE /debug/log15?name=db                      clears the "db" level
+//
+// Without ttl the level is set until changed again. With ttl the previous level
+// is restored after the given duration. PUT fails with 409 Conflict if the handler
+// of the logger filters out the requested level (eg: with LvlFilterHandler), as
+// the new level wouldn't have any effect.
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robert-zaremba/log15"
+)
+
+// Logger describes the level state of a registered logger.
+type Logger struct {
+	Name string `json:"name"`
+	// Level is the effective level of the logger, "all" if none applies.
+	Level string `json:"level"`
+	// Configured is the level set for this logger, empty if it's inherited.
+	Configured string     `json:"configured,omitempty"`
+	Expires    *time.Time `json:"expires,omitempty"`
+}
+
+// Handler is the http.Handler returned by NewHandler.
+type Handler struct {
+	mu      sync.Mutex
+	pending map[string]*revert
+}
+
+// revert restores the level configured before a temporary change.
+type revert struct {
+	lvl     log15.Lvl
+	had     bool
+	expires time.Time
+	timer   *time.Timer
+}
+
+// NewHandler creates an admin Handler.
+func NewHandler() *Handler {
+	return &Handler{pending: map[string]*revert{}}
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	// "db.*" and "db" name the same level, as in log15.SetLevel
+	name := strings.TrimSuffix(q.Get("name"), ".*")
+	_, hasName := q["name"]
+	switch r.Method {
+	case http.MethodGet:
+		if !hasName {
+			names := log15.Names()
+			loggers := make([]Logger, len(names))
+			for i, n := range names {
+				loggers[i] = h.describe(n)
+			}
+			writeJSON(w, http.StatusOK, loggers)
+			return
+		}
+	case http.MethodPut:
+		lvl, err := log15.LvlFromString(q.Get("level"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var ttl time.Duration
+		if s := q.Get("ttl"); s != "" {
+			if ttl, err = time.ParseDuration(s); err != nil || ttl <= 0 {
+				writeError(w, http.StatusBadRequest, "Wrong ttl value, expected a positive duration")
+				return
+			}
+		}
+		if !log15.LvlEnabled(log15.Get(name).GetHandler(), lvl) {
+			writeError(w, http.StatusConflict, "The handler of the logger filters out the "+lvlName(lvl)+" level")
+			return
+		}
+		h.setLevel(name, lvl, ttl)
+	case http.MethodDelete:
+		h.clearLevel(name)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.describe(name))
+}
+
+func (h *Handler) describe(name string) Logger {
+	l := Logger{Name: name, Level: "all"}
+	if lvl, ok := log15.EffectiveLevel(name); ok {
+		l.Level = lvlName(lvl)
+	}
+	if lvl, ok := log15.ConfiguredLevel(name); ok {
+		l.Configured = lvlName(lvl)
+	}
+	h.mu.Lock()
+	if p, ok := h.pending[name]; ok {
+		expires := p.expires
+		l.Expires = &expires
+	}
+	h.mu.Unlock()
+	return l
+}
+
+func (h *Handler) setLevel(name string, lvl log15.Lvl, ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	old, ok := h.pending[name]
+	if ok {
+		old.timer.Stop()
+		delete(h.pending, name)
+	}
+	if ttl > 0 {
+		p := &revert{}
+		if ok {
+			p.lvl, p.had = old.lvl, old.had
+		} else {
+			// remember the level from before the first temporary change
+			p.lvl, p.had = log15.ConfiguredLevel(name)
+		}
+		p.expires = time.Now().Add(ttl)
+		p.timer = time.AfterFunc(ttl, func() { h.expire(name, p) })
+		h.pending[name] = p
+	}
+	log15.SetLevel(name, lvl)
+}
+
+func (h *Handler) clearLevel(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if p, ok := h.pending[name]; ok {
+		p.timer.Stop()
+		delete(h.pending, name)
+	}
+	log15.ClearLevel(name)
+}
+
+func (h *Handler) expire(name string, p *revert) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.pending[name] != p {
+		return // the level was changed again in the meantime
+	}
+	delete(h.pending, name)
+	if p.had {
+		log15.SetLevel(name, p.lvl)
+	} else {
+		log15.ClearLevel(name)
+	}
+}
+
+func lvlName(lvl log15.Lvl) string {
+	return strings.TrimSpace(lvl.String())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,150 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robert-zaremba/log15"
+)
+
+func do(t *testing.T, srv *httptest.Server, method, query string, v interface{}) int {
+	req, err := http.NewRequest(method, srv.URL+"?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// acceptAll replaces the default root handler, which filters out levels below error.
+func acceptAll() func() {
+	old := log15.Root().GetHandler()
+	log15.Root().SetHandler(log15.FuncHandler(func(r *log15.Record) error { return nil }))
+	return func() { log15.Root().SetHandler(old) }
+}
+
+func TestAdminLevels(t *testing.T) {
+	defer acceptAll()()
+	srv := httptest.NewServer(NewHandler())
+	defer srv.Close()
+	log15.Get("admintest.db")
+
+	var loggers []Logger
+	if code := do(t, srv, "GET", "", &loggers); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	found := false
+	for _, l := range loggers {
+		found = found || l.Name == "admintest.db"
+	}
+	if !found || loggers[0].Name != "" {
+		t.Fatalf("expected root and registered loggers, got %+v", loggers)
+	}
+
+	var l Logger
+	do(t, srv, "PUT", "name=admintest&level=warn", &l)
+	if l.Level != "warn" || l.Configured != "warn" || l.Expires != nil {
+		t.Fatalf("wrong logger state after PUT: %+v", l)
+	}
+	l = Logger{}
+	do(t, srv, "GET", "name=admintest.db", &l)
+	if l.Level != "warn" || l.Configured != "" {
+		t.Fatalf("expected child to inherit the level: %+v", l)
+	}
+
+	do(t, srv, "PUT", "name=admintest&level=debug&ttl=50ms", &l)
+	if l.Level != "debug" || l.Expires == nil {
+		t.Fatalf("wrong logger state after temporary PUT: %+v", l)
+	}
+	time.Sleep(200 * time.Millisecond)
+	l = Logger{}
+	do(t, srv, "GET", "name=admintest", &l)
+	if l.Level != "warn" || l.Expires != nil {
+		t.Fatalf("expected the level to be reverted after ttl: %+v", l)
+	}
+
+	l = Logger{}
+	do(t, srv, "DELETE", "name=admintest", &l)
+	if l.Level != "all" || l.Configured != "" {
+		t.Fatalf("expected the level to be cleared: %+v", l)
+	}
+
+	if code := do(t, srv, "PUT", "name=admintest&level=loud", nil); code != http.StatusBadRequest {
+		t.Fatalf("expected bad request for a wrong level, got %d", code)
+	}
+	if code := do(t, srv, "POST", "", nil); code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected method not allowed, got %d", code)
+	}
+}
+
+func TestAdminWildcardName(t *testing.T) {
+	defer acceptAll()()
+	srv := httptest.NewServer(NewHandler())
+	defer srv.Close()
+	log15.SetLevel("admintest2", log15.LvlWarn)
+	defer log15.ClearLevel("admintest2")
+
+	var l Logger
+	do(t, srv, "PUT", "name=admintest2.*&level=debug&ttl=50ms", &l)
+	if l.Name != "admintest2" || l.Level != "debug" || l.Configured != "debug" || l.Expires == nil {
+		t.Fatalf("wrong logger state after temporary PUT: %+v", l)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if lvl, ok := log15.ConfiguredLevel("admintest2.*"); !ok || lvl != log15.LvlWarn {
+		t.Fatalf("expected the previous level to be restored after ttl, got %v %v", lvl, ok)
+	}
+}
+
+func TestAdminRecordsPass(t *testing.T) {
+	srv := httptest.NewServer(NewHandler())
+	defer srv.Close()
+	var mu sync.Mutex
+	var msgs []string
+	capture := log15.FuncHandler(func(r *log15.Record) error {
+		mu.Lock()
+		msgs = append(msgs, r.Msg)
+		mu.Unlock()
+		return nil
+	})
+	old := log15.Root().GetHandler()
+	defer log15.Root().SetHandler(old)
+	log15.SetLevel("", log15.LvlInfo)
+	defer log15.ClearLevel("")
+	defer log15.ClearLevel("admintest3")
+
+	log15.Root().SetHandler(capture)
+	l := log15.Get("admintest3.conn")
+	l.Debug("hidden")
+	if code := do(t, srv, "PUT", "name=admintest3&level=debug", nil); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	l.Debug("shown")
+	log15.Get("admintest").Debug("other")
+	mu.Lock()
+	if len(msgs) != 1 || msgs[0] != "shown" {
+		t.Fatalf("wrong records: %q", msgs)
+	}
+	mu.Unlock()
+
+	// the level would have no effect behind a filter handler
+	log15.Root().SetHandler(log15.LvlFilterHandler(log15.LvlInfo, capture))
+	if code := do(t, srv, "PUT", "name=admintest3&level=trace", nil); code != http.StatusConflict {
+		t.Fatalf("expected conflict, got %d", code)
+	}
+	if lvl, _ := log15.ConfiguredLevel("admintest3"); lvl != log15.LvlDebug {
+		t.Fatalf("the level was changed to %v", lvl)
+	}
+}
@@ -0,0 +1,278 @@
+package log15
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchConfig configures the batching of the handlers which send records
+// asynchronously, like LokiHandler.
+type BatchConfig struct {
+	// BatchSize is the maximum number of records sent in one request. Default: 100.
+	BatchSize int
+	// FlushInterval is the maximum time the records wait for a full batch. Default: 1s.
+	FlushInterval time.Duration
+	// BufferSize is the maximum number of records waiting to be sent. Records
+	// logged when the buffer is full are dropped. Default: 10000.
+	BufferSize int
+	// MaxRetries is the number of attempts to send a batch, after which its
+	// records are dropped. Default: 5.
+	MaxRetries int
+	// MinBackoff is the delay after the first failed attempt. The delay doubles
+	// with each next failure, up to MaxBackoff. Default: 100ms and 30s.
+	MinBackoff, MaxBackoff time.Duration
+	// OnError is called with the send errors. It may log to the handler.
+	OnError func(err error)
+}
+
+func (c BatchConfig) withDefaults() BatchConfig {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = time.Second
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 10000
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 5
+	}
+	c.MinBackoff, c.MaxBackoff = backoffDefaults(c.MinBackoff, c.MaxBackoff)
+	return c
+}
+
+// batcher keeps items in a bounded queue and passes them in batches, in order,
+// to send, which is called from a single background goroutine. send returns
+// the items which should be retried and the number of items rejected for good,
+// with the error. The items to retry are retried with exponential backoff,
+// the rejected ones are counted as dropped.
+type batcher struct {
+	c    BatchConfig
+	send func(batch []interface{}) (retry []interface{}, rejected int, err error)
+	// next returns the size of the next batch, up to BatchSize. If nil,
+	// the batches are as large as possible.
+	next func(queue []interface{}) int
+	done chan struct{} // closed when the loop ends
+	stop chan struct{} // closed by close, interrupts the waiting
+	kick chan struct{} // ends the waiting for a full batch
+
+	mu       sync.Mutex
+	cond     *sync.Cond // signals any change of the fields below
+	queue    []interface{}
+	enqueued uint64
+	sent     uint64 // number of enqueued items which were sent or dropped
+	dropped  uint64
+	closed   bool
+}
+
+// newBatcher starts the batcher, c must have the defaults set.
+func newBatcher(c BatchConfig, next func([]interface{}) int, send func([]interface{}) ([]interface{}, int, error)) *batcher {
+	b := &batcher{
+		c:    c,
+		send: send,
+		next: next,
+		done: make(chan struct{}),
+		stop: make(chan struct{}),
+		kick: make(chan struct{}, 1),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	go b.loop()
+	return b
+}
+
+// add queues the item. It returns ErrHandlerClosed after close.
+func (b *batcher) add(item interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrHandlerClosed
+	}
+	if len(b.queue) >= b.c.BufferSize {
+		b.dropped++
+		return nil
+	}
+	b.queue = append(b.queue, item)
+	b.enqueued++
+	b.cond.Broadcast()
+	if len(b.queue) >= b.c.BatchSize {
+		b.flushNow()
+	}
+	return nil
+}
+
+// flushNow makes the loop send the queued items without waiting for a full batch.
+func (b *batcher) flushNow() {
+	select {
+	case b.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (b *batcher) loop() {
+	defer close(b.done)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		for len(b.queue) == 0 && !b.closed {
+			b.cond.Wait()
+		}
+		if len(b.queue) == 0 {
+			return
+		}
+		if len(b.queue) < b.c.BatchSize && !b.closed {
+			// wait for more items to fill the batch
+			b.mu.Unlock()
+			t := time.NewTimer(b.c.FlushInterval)
+			select {
+			case <-t.C:
+			case <-b.kick:
+			case <-b.stop:
+			}
+			t.Stop()
+			b.mu.Lock()
+		}
+		n := len(b.queue)
+		if n > b.c.BatchSize {
+			n = b.c.BatchSize
+		}
+		if b.next != nil {
+			n = b.next(b.queue[:n])
+		}
+		batch := append([]interface{}(nil), b.queue[:n]...)
+		closed := b.closed
+		b.mu.Unlock()
+		dropped := b.process(batch, closed)
+		b.mu.Lock()
+		for i := 0; i < n; i++ {
+			b.queue[i] = nil
+		}
+		b.queue = b.queue[n:]
+		b.sent += uint64(n)
+		b.dropped += uint64(dropped)
+		b.cond.Broadcast()
+	}
+}
+
+// process sends the batch, retrying the failed items. It returns the number
+// of dropped items.
+func (b *batcher) process(batch []interface{}, closed bool) int {
+	backoff := b.c.MinBackoff
+	dropped := 0
+	for i := 1; ; i++ {
+		retry, rejected, err := b.send(batch)
+		dropped += rejected
+		if err == nil {
+			return dropped
+		}
+		b.report(err)
+		if len(retry) == 0 {
+			return dropped
+		}
+		if i >= b.c.MaxRetries || closed {
+			b.report(fmt.Errorf("log15: dropped %d records after %d attempts", len(retry), i))
+			return dropped + len(retry)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-b.stop:
+			// try once more before giving up
+			closed = true
+		}
+		if backoff *= 2; backoff > b.c.MaxBackoff {
+			backoff = b.c.MaxBackoff
+		}
+		batch = retry
+	}
+}
+
+func (b *batcher) report(err error) {
+	if b.c.OnError != nil {
+		b.c.OnError(err)
+	}
+}
+
+// Dropped returns the number of items dropped because the buffer was full,
+// the retries failed or the receiver rejected them.
+func (b *batcher) Dropped() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// Flush waits until all items added before the call are sent or dropped.
+func (b *batcher) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	target := b.enqueued
+	if b.sent < target {
+		b.flushNow()
+	}
+	for b.sent < target {
+		b.cond.Wait()
+	}
+	return nil
+}
+
+// Close sends the queued items, with a single attempt per batch, and stops the loop.
+func (b *batcher) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+	close(b.stop)
+	<-b.done
+	return nil
+}
+
+// httpError is returned by httpSend for responses with an error status.
+type httpError struct {
+	status int
+	body   string
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("log15: server responded with %d status: %s", e.status, e.body)
+}
+
+// isRetryable reports whether the request failing with err should be retried:
+// on network errors, 429 and 5xx statuses.
+func isRetryable(err error) bool {
+	herr, ok := err.(*httpError)
+	return !ok || herr.status == http.StatusTooManyRequests || herr.status >= 500
+}
+
+// httpPost sends the body with the headers, checks the response status
+// and returns the response body.
+func httpPost(client *http.Client, url, contentType string, body []byte, headers ...http.Header) ([]byte, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range headers {
+		for k, v := range h {
+			req.Header[k] = v
+		}
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return ioutil.ReadAll(resp.Body)
+	}
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, &httpError{resp.StatusCode, strings.TrimSpace(string(b))}
+}
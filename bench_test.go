@@ -31,6 +31,15 @@ func BenchmarkDiscard(b *testing.B) {
 	}
 }
 
+func BenchmarkLvlFilterDiscarded(b *testing.B) {
+	lg := New()
+	lg.SetHandler(LvlFilterHandler(LvlInfo, DiscardHandler()))
+
+	for i := 0; i < b.N; i++ {
+		lg.Debug("test message", "i", i)
+	}
+}
+
 func BenchmarkCallerFileHandler(b *testing.B) {
 	lg := New()
 	lg.SetHandler(CallerFileHandler(DiscardHandler(), false))
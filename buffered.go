@@ -0,0 +1,182 @@
+package log15
+
+import "sync"
+
+// OverflowPolicy defines what a buffered handler does when its buffer is full.
+type OverflowPolicy int
+
+// List of supported overflow policies
+const (
+	// OverflowBlock blocks the caller until there is space in the buffer.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the record being logged.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest buffered record to make space for the new one.
+	OverflowDropOldest
+	// OverflowDropBelowLvl drops the record being logged if it's less severe than
+	// BufferConfig.DropLvl, otherwise it blocks the caller.
+	OverflowDropBelowLvl
+)
+
+// BufferConfig configures BufferedHandlerEx.
+type BufferConfig struct {
+	// Size is the number of records the buffer can hold.
+	Size int
+	// Overflow is the policy applied when the buffer is full.
+	Overflow OverflowPolicy
+	// DropLvl is the least severe level which is not dropped with OverflowDropBelowLvl.
+	DropLvl Lvl
+	// OnError is called with errors returned by the wrapped handler.
+	OnError func(err error, r *Record)
+}
+
+// BufferedHandler writes all records to a buffer of the given size
+// which flushes into the wrapped handler whenever it is available for writing.
+// When the buffer is full the caller blocks. Since these writes happen
+// asynchronously, all writes to a BufferedHandler never return an error
+// and any errors from the wrapped handler are ignored.
+// It is the equivalent of BufferedHandlerEx(BufferConfig{Size: bufSize}, h).
+func BufferedHandler(bufSize int, h Handler) Handler {
+	return BufferedHandlerEx(BufferConfig{Size: bufSize}, h)
+}
+
+// BufferedHandlerEx writes all records to a buffer which flushes into the wrapped
+// handler in a separate goroutine. c.Overflow decides what happens when the buffer
+// is full and c.OnError receives errors from the wrapped handler.
+//
+// Flush waits until all records logged before are written to the wrapped
+// handler. Close writes out the buffer, stops the goroutine and closes
+// the wrapped handler.
+func BufferedHandlerEx(c BufferConfig, h Handler) *Buffered {
+	size := c.Size
+	if size < 1 {
+		size = 1
+	}
+	b := &Buffered{
+		c:    c,
+		h:    h,
+		buf:  make([]*Record, size),
+		done: make(chan struct{}),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	go b.loop()
+	return b
+}
+
+// Buffered is the Handler returned by BufferedHandlerEx.
+type Buffered struct {
+	c    BufferConfig
+	h    Handler
+	done chan struct{}
+
+	mu        sync.Mutex
+	cond      *sync.Cond // signals any change of the fields below
+	buf       []*Record  // ring buffer
+	head, n   int
+	enqueued  uint64
+	processed uint64 // number of enqueued records which were written or dropped
+	dropped   uint64
+	closed    bool
+}
+
+// Log implements Handler interface.
+func (b *Buffered) Log(r *Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.n == len(b.buf) && !b.closed {
+		switch {
+		case b.c.Overflow == OverflowDropNewest,
+			b.c.Overflow == OverflowDropBelowLvl && r.Lvl > b.c.DropLvl:
+			b.dropped++
+			return nil
+		case b.c.Overflow == OverflowDropOldest:
+			b.pop()
+			b.dropped++
+			b.processed++
+		default:
+			b.cond.Wait()
+		}
+	}
+	if b.closed {
+		return ErrHandlerClosed
+	}
+	b.buf[(b.head+b.n)%len(b.buf)] = r
+	b.n++
+	b.enqueued++
+	b.cond.Broadcast()
+	return nil
+}
+
+// pop removes the oldest record from the buffer. It must be called with b.mu held.
+func (b *Buffered) pop() *Record {
+	r := b.buf[b.head]
+	b.buf[b.head] = nil
+	b.head = (b.head + 1) % len(b.buf)
+	b.n--
+	return r
+}
+
+func (b *Buffered) loop() {
+	defer close(b.done)
+	b.mu.Lock()
+	for {
+		for b.n == 0 && !b.closed {
+			b.cond.Wait()
+		}
+		if b.n == 0 {
+			b.mu.Unlock()
+			return
+		}
+		r := b.pop()
+		b.cond.Broadcast()
+		b.mu.Unlock()
+
+		if err := b.h.Log(r); err != nil && b.c.OnError != nil {
+			b.c.OnError(err, r)
+		}
+
+		b.mu.Lock()
+		b.processed++
+		b.cond.Broadcast()
+	}
+}
+
+// Enabled reports whether the wrapped handler is enabled for the level.
+func (b *Buffered) Enabled(lvl Lvl) bool {
+	return LvlEnabled(b.h, lvl)
+}
+
+// Dropped returns the number of records dropped because the buffer was full.
+func (b *Buffered) Dropped() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// Flush waits until all records logged before the call are written
+// to the wrapped handler and flushes it.
+func (b *Buffered) Flush() error {
+	b.mu.Lock()
+	target := b.enqueued
+	for b.processed < target {
+		b.cond.Wait()
+	}
+	b.mu.Unlock()
+	return TryFlush(b.h)
+}
+
+// Close writes out all buffered records, stops the writing goroutine
+// and closes the wrapped handler. Records logged after Close are rejected
+// with ErrHandlerClosed.
+func (b *Buffered) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+	<-b.done
+	return TryClose(b.h)
+}
@@ -0,0 +1,128 @@
+package log15
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedHandler records messages. It blocks on the first record until the gate is opened.
+type gatedHandler struct {
+	started chan struct{}
+	gate    chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	msgs    []string
+}
+
+func newGatedHandler() *gatedHandler {
+	return &gatedHandler{started: make(chan struct{}), gate: make(chan struct{})}
+}
+
+func (h *gatedHandler) Log(r *Record) error {
+	h.once.Do(func() {
+		close(h.started)
+		<-h.gate
+	})
+	h.mu.Lock()
+	h.msgs = append(h.msgs, r.Msg)
+	h.mu.Unlock()
+	return nil
+}
+
+func testOverflow(t *testing.T, c BufferConfig, lvl Lvl, expected []string, dropped uint64) {
+	h := newGatedHandler()
+	c.Size = 2
+	b := BufferedHandlerEx(c, h)
+	b.Log(&Record{Msg: "r0", Lvl: lvl})
+	<-h.started
+	for _, msg := range []string{"r1", "r2", "r3"} {
+		if err := b.Log(&Record{Msg: msg, Lvl: lvl}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(h.gate)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h.msgs, expected) {
+		t.Fatalf("overflow %d: got records %v, expected %v", c.Overflow, h.msgs, expected)
+	}
+	if b.Dropped() != dropped {
+		t.Fatalf("overflow %d: got %d dropped records, expected %d", c.Overflow, b.Dropped(), dropped)
+	}
+}
+
+func TestBufferedOverflow(t *testing.T) {
+	t.Parallel()
+
+	testOverflow(t, BufferConfig{Overflow: OverflowDropNewest}, LvlInfo,
+		[]string{"r0", "r1", "r2"}, 1)
+	testOverflow(t, BufferConfig{Overflow: OverflowDropOldest}, LvlInfo,
+		[]string{"r0", "r2", "r3"}, 1)
+	testOverflow(t, BufferConfig{Overflow: OverflowDropBelowLvl, DropLvl: LvlWarn}, LvlInfo,
+		[]string{"r0", "r1", "r2"}, 1)
+}
+
+func TestBufferedOverflowBlock(t *testing.T) {
+	t.Parallel()
+
+	h := newGatedHandler()
+	b := BufferedHandlerEx(BufferConfig{Size: 1, Overflow: OverflowDropBelowLvl, DropLvl: LvlWarn}, h)
+	b.Log(&Record{Msg: "r0"})
+	<-h.started
+	b.Log(&Record{Msg: "r1"})
+
+	logged := make(chan struct{})
+	go func() {
+		b.Log(&Record{Msg: "r2", Lvl: LvlError})
+		close(logged)
+	}()
+	select {
+	case <-logged:
+		t.Fatalf("expected severe record to block when the buffer is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(h.gate)
+	select {
+	case <-logged:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected severe record to be logged once the buffer is drained")
+	}
+	b.Flush()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !reflect.DeepEqual(h.msgs, []string{"r0", "r1", "r2"}) {
+		t.Fatalf("got records %v", h.msgs)
+	}
+}
+
+func TestBufferedOnError(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var failed []string
+	b := BufferedHandlerEx(BufferConfig{
+		Size: 10,
+		OnError: func(err error, r *Record) {
+			mu.Lock()
+			failed = append(failed, r.Msg)
+			mu.Unlock()
+		},
+	}, FuncHandler(func(r *Record) error {
+		if r.Lvl == LvlError {
+			return errors.New("write failed")
+		}
+		return nil
+	}))
+	b.Log(&Record{Msg: "ok", Lvl: LvlInfo})
+	b.Log(&Record{Msg: "fail", Lvl: LvlError})
+	b.Flush()
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(failed, []string{"fail"}) {
+		t.Fatalf("expected error callback for the failed record, got %v", failed)
+	}
+}
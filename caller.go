@@ -23,6 +23,8 @@ func findCaller(ctx []interface{}) CallerCtx {
 // the calling function to the context with key "caller".
 // If short then caller filename is shortened to the parent directory where the calling
 // function is placed. Otherwise absolute path is used.
+// The returned function doesn't propagate Close, Flush nor Enabled to h. Use
+// PassFuncHandler(CallerFileHandler(h, short), h) in handler trees which need them.
 func CallerFileHandler(h Handler, short bool) FuncHandlerT {
 	return func(r *Record) error {
 		caller := fmt.Sprintf("%#v", r.Call)
@@ -51,10 +53,10 @@ func shortFilename(filename string) string {
 // CallerFuncHandler returns a Handler that adds the calling function name to
 // the context with key "fn".
 func CallerFuncHandler(h Handler) Handler {
-	return FuncHandler(func(r *Record) error {
+	return passFuncHandler(func(r *Record) error {
 		r.Ctx = append(r.Ctx, "fn", fmt.Sprintf("%+n", r.Call)) // r.Call has a custom formatter
 		return h.Log(r)
-	})
+	}, h)
 }
 
 // CallerStackHandler returns a Handler that adds a stack trace to the context
@@ -63,11 +65,11 @@ func CallerFuncHandler(h Handler) Handler {
 // Each call site is formatted according to format. See the documentation of
 // package github.com/go-stack/stack for the list of supported formats.
 func CallerStackHandler(format string, h Handler) Handler {
-	return FuncHandler(func(r *Record) error {
+	return passFuncHandler(func(r *Record) error {
 		s := stack.Trace().TrimBelow(r.Call).TrimRuntime()
 		if len(s) > 0 {
 			r.Ctx = append(r.Ctx, "stack", fmt.Sprintf(format, s))
 		}
 		return h.Log(r)
-	})
+	}, h)
 }
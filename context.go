@@ -0,0 +1,69 @@
+package log15
+
+import (
+	"context"
+	"time"
+)
+
+type loggerKey struct{}
+
+// WithContext returns a copy of c which carries the logger l.
+// Use FromContext to retrieve it.
+func WithContext(c context.Context, l Logger) context.Context {
+	return context.WithValue(c, loggerKey{}, l)
+}
+
+// FromContext returns the logger stored in c by WithContext.
+// If there is no such logger, the root logger is returned.
+func FromContext(c context.Context) Logger {
+	if c != nil {
+		if l, ok := c.Value(loggerKey{}).(Logger); ok {
+			return l
+		}
+	}
+	return root
+}
+
+// ContextExtractor returns key/value pairs extracted from a context.Context.
+// It's used by ContextHandler.
+type ContextExtractor func(c context.Context) []interface{}
+
+// ContextValue returns a ContextExtractor which adds the c.Value(key)
+// to the record context under the given name, if the value is not nil.
+func ContextValue(name string, key interface{}) ContextExtractor {
+	return func(c context.Context) []interface{} {
+		if v := c.Value(key); v != nil {
+			return []interface{}{name, v}
+		}
+		return nil
+	}
+}
+
+// ContextDeadline returns a ContextExtractor which adds the time left until the context
+// deadline to the record context under the given name.
+func ContextDeadline(name string) ContextExtractor {
+	return func(c context.Context) []interface{} {
+		if d, ok := c.Deadline(); ok {
+			return []interface{}{name, time.Until(d)}
+		}
+		return nil
+	}
+}
+
+// ContextHandler returns a Handler which appends to the record context the key/value pairs
+// returned by the extractors for the record's Context. Records logged without context
+// are passed through unchanged. For example, to log the request ID stored in the context:
+//
+//     h = log.ContextHandler(h, log.ContextValue("req_id", reqIDKey))
+//     logger.InfoCtx(ctx, "request handled")
+//
+func ContextHandler(h Handler, extractors ...ContextExtractor) Handler {
+	return passFuncHandler(func(r *Record) error {
+		if r.Context != nil {
+			for _, e := range extractors {
+				r.Ctx = append(r.Ctx, e(r.Context)...)
+			}
+		}
+		return h.Log(r)
+	}, h)
+}
@@ -0,0 +1,57 @@
+package log15
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type ctxKey string
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	if FromContext(context.Background()) != Root() {
+		t.Fatalf("expected root logger for an empty context")
+	}
+	l := New("req_id", 1)
+	c := WithContext(context.Background(), l)
+	if FromContext(c) != l {
+		t.Fatalf("expected logger stored in the context")
+	}
+}
+
+func TestContextHandler(t *testing.T) {
+	t.Parallel()
+
+	l := New()
+	h, r := testHandler()
+	l.SetHandler(ContextHandler(h, ContextValue("user", ctxKey("user")), ContextDeadline("deadline")))
+
+	c := context.WithValue(context.Background(), ctxKey("user"), "alice")
+	l.InfoCtx(c, "with context", "x", 1)
+	if r.Context != c {
+		t.Fatalf("expected the context in the record")
+	}
+	if len(r.Ctx) != 4 || r.Ctx[2] != "user" || r.Ctx[3] != "alice" {
+		t.Fatalf("expected the context value in the record context, got %v", r.Ctx)
+	}
+
+	c, cancel := context.WithTimeout(c, time.Minute)
+	defer cancel()
+	l.ErrorCtx(c, "with deadline")
+	if len(r.Ctx) != 4 || r.Ctx[2] != "deadline" {
+		t.Fatalf("expected the deadline in the record context, got %v", r.Ctx)
+	}
+	if d, ok := r.Ctx[3].(time.Duration); !ok || d <= 0 {
+		t.Fatalf("expected the deadline in the record context, got %v", r.Ctx)
+	}
+	if r.Lvl != LvlError {
+		t.Fatalf("got level %v, expected %v", r.Lvl, LvlError)
+	}
+
+	l.Info("without context")
+	if r.Context != nil || len(r.Ctx) != 0 {
+		t.Fatalf("expected no context in the record, got %v", r.Ctx)
+	}
+}
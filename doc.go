@@ -298,6 +298,28 @@ Tab example, we would prefer to set up our Logger like so:
 Now we'll have a unique traceable identifier even across loading new urls, but
 we'll still be able to see the tab's current url in the log messages.
 
+Request Context
+
+Loggers can travel along with a context.Context. WithContext stores a logger in
+the context and FromContext retrieves it (or the root logger). The XxxCtx logging
+methods attach the context to the Record, so handlers can read values from it.
+ContextHandler appends context values to the record:
+
+    ctx = log.WithContext(ctx, log.New("req_id", reqID))
+    ...
+    log.FromContext(ctx).InfoCtx(ctx, "query done", "rows", n)
+
+Closing Handlers
+
+Handlers which hold files, sockets or goroutines implement the optional Closer
+and Flusher interfaces. Handlers which wrap other handlers (MultiHandler,
+LvlFilterHandler, BufferedHandler, ...) pass Close and Flush down to them.
+Before your program exits, close the root handler tree so that no record is lost:
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    log.Shutdown(ctx)
+
 Must
 
 For all Handler functions which can return an error, there is a version of that
@@ -0,0 +1,198 @@
+package log15
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ElasticConfig configures ElasticHandler.
+type ElasticConfig struct {
+	// URL of the cluster, eg: http://localhost:9200
+	URL string
+	// Index is the prefix of the index names. Default: "logs".
+	Index string
+	// IndexDate is the time layout of the index name suffix, formatted with
+	// the record time in UTC. Records go to daily indices by default.
+	// Default: "2006.01.02".
+	IndexDate string
+	// Username and Password enable basic authentication.
+	Username, Password string
+	// APIKey is sent in the Authorization header, if set. It's the base64
+	// encoded id:api_key, as returned by the create API key API.
+	APIKey string
+	// Header is added to the bulk requests.
+	Header http.Header
+	// Client sends the bulk requests. Default: a client with a 30s timeout.
+	Client *http.Client
+	// MaxBatchBytes limits the size of the bulk request body. Default: 5MB.
+	MaxBatchBytes int
+	// Batch configures batching and retries. The default BatchSize is 1000.
+	Batch BatchConfig
+}
+
+// ElasticHandler returns a handler which indexes records in Elasticsearch
+// or OpenSearch with the _bulk API. The documents are the same JSON objects
+// JsonFormat writes. They are created in the index named c.Index + "-" +
+// the record date, eg: logs-app-2026.10.17. The create action works both
+// with indices and data streams.
+//
+// Records are sent asynchronously in batches of up to c.Batch.BatchSize records
+// and c.MaxBatchBytes bytes. Rejected documents are retried if their status
+// is 429 or 5xx, and dropped otherwise. Whole requests are retried on network
+// errors, 429 and 5xx statuses. Flush waits for the logged records to be sent
+// and Close sends the buffered records.
+func ElasticHandler(c ElasticConfig) (*Elastic, error) {
+	if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+		return nil, errors.New("Wrong `URL` value " + c.URL + ", should be a http(s) URL of the cluster")
+	}
+	if c.Index == "" {
+		c.Index = "logs"
+	}
+	if c.IndexDate == "" {
+		c.IndexDate = "2006.01.02"
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if c.MaxBatchBytes <= 0 {
+		c.MaxBatchBytes = 5 << 20
+	}
+	if c.Batch.BatchSize <= 0 {
+		c.Batch.BatchSize = 1000
+	}
+	e := &Elastic{c: c, url: strings.TrimRight(c.URL, "/") + "/_bulk", header: http.Header{}}
+	for k, v := range c.Header {
+		e.header[k] = v
+	}
+	switch {
+	case c.APIKey != "":
+		e.header.Set("Authorization", "ApiKey "+c.APIKey)
+	case c.Username != "":
+		e.header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password)))
+	}
+	e.lazy = LazyHandler(FuncHandler(e.enqueue))
+	e.batcher = newBatcher(c.Batch.withDefaults(), e.next, e.send)
+	return e, nil
+}
+
+// Elastic is the Handler returned by ElasticHandler.
+type Elastic struct {
+	*batcher
+	c      ElasticConfig
+	url    string
+	header http.Header
+	lazy   Handler
+}
+
+// elasticItem is the action and document lines of a bulk request.
+type elasticItem []byte
+
+// Log implements Handler interface.
+func (e *Elastic) Log(r *Record) error {
+	return e.lazy.Log(r)
+}
+
+func (e *Elastic) enqueue(r *Record) error {
+	doc, err := json.Marshal(jsonProps(r))
+	if err != nil {
+		doc, _ = json.Marshal(map[string]interface{}{
+			r.KeyNames.Time: r.Time, r.KeyNames.Lvl: r.Lvl.String(), r.KeyNames.Msg: r.Msg, errorKey: err.Error(),
+		})
+	}
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	action, _ := json.Marshal(map[string]interface{}{
+		"create": map[string]string{"_index": e.c.Index + "-" + t.UTC().Format(e.c.IndexDate)},
+	})
+	item := make(elasticItem, 0, len(action)+len(doc)+2)
+	item = append(append(item, action...), '\n')
+	item = append(append(item, doc...), '\n')
+	return e.batcher.add(item)
+}
+
+// next limits the batch to c.MaxBatchBytes. A larger record is sent alone.
+func (e *Elastic) next(queue []interface{}) int {
+	size := len(queue[0].(elasticItem))
+	n := 1
+	for n < len(queue) && size+len(queue[n].(elasticItem)) <= e.c.MaxBatchBytes {
+		size += len(queue[n].(elasticItem))
+		n++
+	}
+	return n
+}
+
+// elasticBulkResponse is the part of the bulk API response used by the handler.
+type elasticBulkResponse struct {
+	Errors bool
+	Items  []map[string]struct {
+		Status int
+		Error  struct {
+			Type   string
+			Reason string
+		}
+	}
+}
+
+func (e *Elastic) send(batch []interface{}) ([]interface{}, int, error) {
+	var body bytes.Buffer
+	for _, item := range batch {
+		body.Write(item.(elasticItem))
+	}
+	resp, err := httpPost(e.c.Client, e.url, "application/x-ndjson", body.Bytes(), e.header)
+	if err != nil {
+		if isRetryable(err) {
+			return batch, 0, err
+		}
+		return nil, len(batch), err
+	}
+	var br elasticBulkResponse
+	if err := json.Unmarshal(resp, &br); err != nil {
+		return nil, 0, fmt.Errorf("log15: wrong bulk response: %v", err)
+	}
+	if !br.Errors {
+		return nil, 0, nil
+	}
+	if len(br.Items) != len(batch) {
+		return nil, 0, fmt.Errorf("log15: bulk response has %d items, expected %d", len(br.Items), len(batch))
+	}
+	var retry []interface{}
+	var rejected int
+	var reason string
+	for i, item := range br.Items {
+		for _, res := range item {
+			switch {
+			case res.Status < 300:
+			case res.Status == http.StatusTooManyRequests || res.Status >= 500:
+				retry = append(retry, batch[i])
+			default:
+				rejected++
+				if reason == "" {
+					reason = res.Error.Type + ": " + res.Error.Reason
+				}
+			}
+		}
+	}
+	switch {
+	case rejected > 0:
+		return retry, rejected, fmt.Errorf("log15: %d documents rejected (%s), %d to retry", rejected, reason, len(retry))
+	case len(retry) > 0:
+		return retry, 0, fmt.Errorf("log15: %d documents to retry", len(retry))
+	}
+	return nil, 0, nil
+}
+
+func (m muster) ElasticHandler(c ElasticConfig) *Elastic {
+	h, err := ElasticHandler(c)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
@@ -0,0 +1,205 @@
+package log15
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type elasticDoc struct {
+	index string
+	doc   string
+}
+
+// elasticServer implements the bulk API. status returns the status of the
+// document, which is accepted with 201 or rejected with the other statuses.
+func elasticServer(t *testing.T, status func(doc map[string]interface{}) int) (*httptest.Server, chan elasticDoc) {
+	docs := make(chan elasticDoc, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("wrong request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		if r.Header.Get("Authorization") != "ApiKey key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var resp struct {
+			Errors bool                                `json:"errors"`
+			Items  []map[string]map[string]interface{} `json:"items"`
+		}
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			var action map[string]map[string]string
+			if err := json.Unmarshal(sc.Bytes(), &action); err != nil {
+				t.Error(err)
+			}
+			sc.Scan()
+			var doc map[string]interface{}
+			if err := json.Unmarshal(sc.Bytes(), &doc); err != nil {
+				t.Error(err)
+			}
+			st := status(doc)
+			item := map[string]interface{}{"status": st}
+			if st == 201 {
+				docs <- elasticDoc{action["create"]["_index"], sc.Text()}
+			} else {
+				resp.Errors = true
+				item["error"] = map[string]string{"type": "mapper_parsing_exception", "reason": "failed to parse"}
+			}
+			resp.Items = append(resp.Items, map[string]map[string]interface{}{"create": item})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	return srv, docs
+}
+
+func expectElasticDocs(t *testing.T, docs chan elasticDoc, expected ...elasticDoc) {
+	for _, e := range expected {
+		select {
+		case d := <-docs:
+			if d != e {
+				t.Fatalf("wrong document %v, expected %v", d, e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %v", e)
+		}
+	}
+}
+
+func TestElasticHandler(t *testing.T) {
+	t.Parallel()
+
+	srv, docs := elasticServer(t, func(map[string]interface{}) int { return 201 })
+	defer srv.Close()
+	h, err := ElasticHandler(ElasticConfig{URL: srv.URL + "/", Index: "logs-app", APIKey: "key",
+		Batch: BatchConfig{FlushInterval: time.Minute}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	keys := RecordKeyNames{Time: "t", Lvl: "lvl", Msg: "msg"}
+	r1 := &Record{Time: time.Date(2026, 10, 17, 23, 30, 0, 0, time.FixedZone("", -3600)), Lvl: LvlInfo, Msg: "a",
+		KeyNames: keys, Ctx: []interface{}{"n", 1, "err", errors.New("fail"), "d", time.Second}}
+	r2 := &Record{Time: time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC), Lvl: LvlWarn, Msg: "b", KeyNames: keys}
+	h.Log(r1)
+	h.Log(r2)
+	h.Flush()
+	// the documents match the JsonFormat output
+	jsonFmt := JsonFormatEx(false, false)
+	expectElasticDocs(t, docs,
+		elasticDoc{"logs-app-2026.10.18", string(jsonFmt.Format(r1))},
+		elasticDoc{"logs-app-2026.10.18", string(jsonFmt.Format(r2))})
+
+	if _, err := ElasticHandler(ElasticConfig{URL: "localhost:9200"}); err == nil {
+		t.Fatalf("expected URL error")
+	}
+}
+
+func TestElasticHandlerKeylessCtx(t *testing.T) {
+	t.Parallel()
+
+	srv, docs := elasticServer(t, func(map[string]interface{}) int { return 201 })
+	defer srv.Close()
+	h := Must.ElasticHandler(ElasticConfig{URL: srv.URL, APIKey: "key", IndexDate: "2006"})
+	defer h.Close()
+	l := New()
+	l.SetHandler(h)
+	l.Error("failed", errors.New("timeout"), CallerCtx("db.go:7"), "n", 1, "last")
+	h.Flush()
+
+	select {
+	case d := <-docs:
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(d.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["err"] != "timeout" || doc["caller"] != "db.go:7" || doc["n"] != 1.0 ||
+			doc[errorKey] != "no value for last key last" {
+			t.Fatalf("wrong document %s", d.doc)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for the document")
+	}
+}
+
+func TestElasticHandlerPartialFailure(t *testing.T) {
+	t.Parallel()
+
+	// "retried" is rejected with 429 twice
+	var retries int32 = 2
+	srv, docs := elasticServer(t, func(doc map[string]interface{}) int {
+		switch doc["msg"] {
+		case "retried":
+			if atomic.AddInt32(&retries, -1) >= 0 {
+				return 429
+			}
+		case "rejected":
+			return 400
+		}
+		return 201
+	})
+	defer srv.Close()
+	var mu sync.Mutex
+	var errs []string
+	h := Must.ElasticHandler(ElasticConfig{URL: srv.URL, APIKey: "key", IndexDate: "2006",
+		Batch: BatchConfig{MinBackoff: time.Millisecond, OnError: func(err error) {
+			mu.Lock()
+			errs = append(errs, err.Error())
+			mu.Unlock()
+		}}})
+	keys := RecordKeyNames{Time: "t", Lvl: "lvl", Msg: "msg"}
+	ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, msg := range []string{"sent", "retried", "rejected"} {
+		h.Log(&Record{Time: ts, Msg: msg, KeyNames: keys})
+	}
+	h.Flush()
+	defer h.Close()
+	expectElasticDocs(t, docs,
+		elasticDoc{"logs-2026", `{"lvl":"criti","msg":"sent","t":"2026-01-01T00:00:00Z"}`},
+		elasticDoc{"logs-2026", `{"lvl":"criti","msg":"retried","t":"2026-01-01T00:00:00Z"}`})
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 2 || errs[0] != "log15: 1 documents rejected (mapper_parsing_exception: failed to parse), 1 to retry" ||
+		errs[1] != "log15: 1 documents to retry" {
+		t.Fatalf("wrong errors: %q", errs)
+	}
+	// the rejected document is dropped
+	if h.Dropped() != 1 {
+		t.Fatalf("wrong number of dropped records: %d", h.Dropped())
+	}
+}
+
+func TestElasticHandlerBatchBytes(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var sizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
+		mu.Lock()
+		sizes = append(sizes, bytes.Count(buf.Bytes(), []byte("\n"))/2)
+		mu.Unlock()
+		w.Write([]byte(`{"errors":false}`))
+	}))
+	defer srv.Close()
+	h := Must.ElasticHandler(ElasticConfig{URL: srv.URL, MaxBatchBytes: 250,
+		Batch: BatchConfig{FlushInterval: time.Minute}})
+	for i := 0; i < 5; i++ {
+		h.Log(&Record{Msg: "0123456789", KeyNames: RecordKeyNames{Time: "t", Lvl: "lvl", Msg: "msg"}})
+	}
+	h.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Fatalf("wrong batches: %v", sizes)
+	}
+}
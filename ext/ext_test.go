@@ -108,3 +108,47 @@ func TestErrorHandler(t *testing.T) {
 		t.Fatalf("Expected debug level message to be escalated to LvlError")
 	}
 }
+
+func TestSpeculativeTreeFlush(t *testing.T) {
+	t.Parallel()
+
+	recs := make(chan *log.Record, 10)
+	spec := SpeculativeHandler(10, log.ChannelHandler(recs))
+	spec.Log(&log.Record{Msg: "speculative"})
+	if err := log.TryFlush(log.MultiHandler(spec)); err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 0 {
+		t.Fatalf("expected the tree flush not to dump the buffered records")
+	}
+	spec.Flush()
+	if len(recs) != 1 {
+		t.Fatalf("expected Flush to dump the buffered records")
+	}
+}
+
+type closeRecorder struct {
+	closed bool
+}
+
+func (h *closeRecorder) Log(r *log.Record) error { return nil }
+func (h *closeRecorder) Close() error {
+	h.closed = true
+	return nil
+}
+
+func TestCloseWrappers(t *testing.T) {
+	t.Parallel()
+
+	h1, h2 := &closeRecorder{}, &closeRecorder{}
+	spec := SpeculativeHandler(10, h1)
+	hs := HotSwapHandler(EscalateErrHandler(spec))
+	if err := log.TryClose(hs); err != nil || !h1.closed {
+		t.Fatalf("expected the speculative handler to close its child, err: %v", err)
+	}
+
+	hs.Swap(h2)
+	if err := log.TryClose(hs); err != nil || !h2.closed {
+		t.Fatalf("expected hot swap to close the current handler, err: %v", err)
+	}
+}
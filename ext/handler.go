@@ -29,7 +29,7 @@ import (
 //     }
 //
 func EscalateErrHandler(h log.Handler) log.Handler {
-	return log.FuncHandler(func(r *log.Record) error {
+	return log.ParentFuncHandler(func(r *log.Record) error {
 		if r.Lvl > log.LvlError {
 			for i := 1; i < len(r.Ctx); i++ {
 				if v, ok := r.Ctx[i].(error); ok && v != nil {
@@ -39,7 +39,7 @@ func EscalateErrHandler(h log.Handler) log.Handler {
 			}
 		}
 		return h.Log(r)
-	})
+	}, h)
 }
 
 // SpeculativeHandler is a handler for speculative logging. It
@@ -74,7 +74,9 @@ func (h *Speculative) Log(r *log.Record) error {
 	return nil
 }
 
-// Flush logs all records on the handler.
+// Flush logs all records on the handler and flushes the handler.
+// Speculative doesn't implement log.Flusher: flushing the handler tree
+// (eg: with log.Shutdown) doesn't dump the buffered records.
 func (h *Speculative) Flush() {
 	recs := make([]*log.Record, 0)
 	func() {
@@ -94,6 +96,19 @@ func (h *Speculative) Flush() {
 	for _, r := range recs {
 		h.handler.Log(r)
 	}
+	log.TryFlush(h.handler)
+}
+
+// Close discards all buffered records and closes the wrapped handler.
+func (h *Speculative) Close() error {
+	h.mu.Lock()
+	for i := range h.recs {
+		h.recs[i] = nil
+	}
+	h.full = false
+	h.idx = 0
+	h.mu.Unlock()
+	return log.TryClose(h.handler)
 }
 
 // HotSwapHandler wraps another handler that may swapped out
@@ -122,15 +137,31 @@ func (h *HotSwap) Swap(newHandler log.Handler) {
 	atomic.StorePointer(&h.handler, unsafe.Pointer(&newHandler))
 }
 
+// Enabled reports whether the current handler is enabled for the level.
+func (h *HotSwap) Enabled(lvl log.Lvl) bool {
+	return log.LvlEnabled(*(*log.Handler)(atomic.LoadPointer(&h.handler)), lvl)
+}
+
+// Close closes the current handler.
+func (h *HotSwap) Close() error {
+	return log.TryClose(*(*log.Handler)(atomic.LoadPointer(&h.handler)))
+}
+
+// Flush flushes the current handler.
+func (h *HotSwap) Flush() error {
+	return log.TryFlush(*(*log.Handler)(atomic.LoadPointer(&h.handler)))
+}
+
 // FatalHandler makes critical errors exit the program
 // immediately, much like the log.Fatal* methods from the
-// standard log package
+// standard log package. The wrapped handler is flushed before exiting.
 func FatalHandler(h log.Handler) log.Handler {
-	return log.FuncHandler(func(r *log.Record) error {
+	return log.PassFuncHandler(func(r *log.Record) error {
 		err := h.Log(r)
 		if r.Lvl == log.LvlCrit {
+			log.TryFlush(h)
 			os.Exit(1)
 		}
 		return err
-	})
+	}, h)
 }
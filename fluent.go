@@ -0,0 +1,270 @@
+package log15
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// FluentMode is the Fluentd Forward protocol mode used by FluentHandler.
+type FluentMode int
+
+// List of supported Forward protocol modes
+const (
+	// FluentMessage sends each record in a separate message: [tag, time, record].
+	FluentMessage FluentMode = iota
+	// FluentForward sends batches of records: [tag, [[time, record], ...]].
+	FluentForward
+	// FluentPackedForward sends batches of records as a binary stream of
+	// msgpack encoded entries: [tag, bin(entries)].
+	FluentPackedForward
+)
+
+// FluentConfig configures FluentHandler.
+type FluentConfig struct {
+	// Mode of the Forward protocol.
+	Mode FluentMode
+	// Tag of the records. The logger name, if set, is appended to it after a dot.
+	// Default: "log15".
+	Tag string
+	// Ack requests acknowledgments of each message, which are resent if an
+	// acknowledgment doesn't come in AckTimeout.
+	Ack bool
+	// AckTimeout is the time to wait for an acknowledgment. Default: 10s.
+	AckTimeout time.Duration
+	// BatchSize is the maximum number of records sent in one message, in
+	// the Forward and PackedForward modes. Default: 100.
+	BatchSize int
+	// FlushInterval is the maximum time the records wait for a batch. Default: 1s.
+	FlushInterval time.Duration
+	// BufferSize is the maximum number of records waiting to be sent. Records
+	// logged when the buffer is full are dropped. Default: 10000.
+	BufferSize int
+	// MaxRetries is the number of attempts to resend a message, after which
+	// the message is dropped. Default: 5.
+	MaxRetries int
+	// Reconnect configures the dialing and the backoff between the retries.
+	// Only DialTimeout, WriteTimeout, MinBackoff, MaxBackoff, OnError and
+	// TLSConfig are used.
+	Reconnect ReconnectConfig
+}
+
+// FluentHandler returns a handler which sends records to Fluentd or Fluent Bit
+// using the Forward protocol. Records are msgpack maps with the level, message,
+// time and context key/value pairs. Errors logged without keys are under
+// the "err" key.
+//
+// Records are sent asynchronously: they wait in a buffer, are grouped into
+// batches per tag and sent in order. Failed messages are resent after
+// a reconnect with exponential backoff. Close sends the buffered records.
+func FluentHandler(network, addr string, c FluentConfig) (*Fluent, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, errors.New("Wrong `network` value " + network + ", should be tcp or unix")
+	}
+	if c.Tag == "" {
+		c.Tag = "log15"
+	}
+	if c.AckTimeout <= 0 {
+		c.AckTimeout = 10 * time.Second
+	}
+	if c.Mode == FluentMessage {
+		c.BatchSize = 1
+	}
+	c.Reconnect = c.Reconnect.withDefaults()
+	f := &Fluent{c: c, dial: c.Reconnect.dialer(network, addr)}
+	f.lazy = LazyHandler(FuncHandler(f.enqueue))
+	f.batcher = newBatcher(BatchConfig{
+		BatchSize:     c.BatchSize,
+		FlushInterval: c.FlushInterval,
+		BufferSize:    c.BufferSize,
+		MaxRetries:    c.MaxRetries,
+		MinBackoff:    c.Reconnect.MinBackoff,
+		MaxBackoff:    c.Reconnect.MaxBackoff,
+		OnError:       c.Reconnect.OnError,
+	}.withDefaults(), f.next, f.send)
+	return f, nil
+}
+
+// Fluent is the Handler returned by FluentHandler.
+type Fluent struct {
+	*batcher
+	c    FluentConfig
+	dial func() (net.Conn, error)
+	lazy Handler
+
+	conn net.Conn // used only by the batcher loop
+	rd   *bufio.Reader
+}
+
+type fluentEntry struct {
+	tag    string
+	record []byte // msgpack encoded [time, record]
+}
+
+// Log implements Handler interface.
+func (f *Fluent) Log(r *Record) error {
+	return f.lazy.Log(r)
+}
+
+func (f *Fluent) enqueue(r *Record) error {
+	e := fluentEntry{tag: f.c.Tag, record: encodeFluentRecord(r)}
+	if r.Name != "" {
+		e.tag += "." + r.Name
+	}
+	return f.batcher.add(e)
+}
+
+// encodeFluentRecord encodes the [time, record] entry.
+func encodeFluentRecord(r *Record) []byte {
+	ctx := make([]interface{}, 0, 4+len(r.Ctx))
+	ctx = append(ctx, r.KeyNames.Lvl, r.Lvl.String(), r.KeyNames.Msg, r.Msg)
+	errs := walkCtx(r.Ctx, func(k string, v interface{}) {
+		ctx = append(ctx, k, scalarValue(v))
+	})
+	if len(errs) > 0 {
+		ctx = append(ctx, "err", joinErrors(errs))
+	}
+	w := &msgpackWriter{}
+	w.putArray(2)
+	w.putEventTime(r.Time)
+	w.putMap(len(ctx) / 2)
+	for i := 0; i < len(ctx); i += 2 {
+		w.putString(ctx[i].(string))
+		w.putValue(ctx[i+1])
+	}
+	return w.buf
+}
+
+// next returns the number of the oldest records with the same tag.
+func (f *Fluent) next(queue []interface{}) int {
+	n := 1
+	for n < len(queue) && queue[n].(fluentEntry).tag == queue[0].(fluentEntry).tag {
+		n++
+	}
+	return n
+}
+
+// send sends the message with the entries, reconnecting on failures.
+func (f *Fluent) send(batch []interface{}) ([]interface{}, int, error) {
+	entries := make([]fluentEntry, len(batch))
+	for i, e := range batch {
+		entries[i] = e.(fluentEntry)
+	}
+	msg, chunk := f.encode(entries)
+	if err := f.write(msg, chunk); err != nil {
+		f.disconnect()
+		return batch, 0, err
+	}
+	return nil, 0, nil
+}
+
+// encode encodes the entries, which have the same tag, in the configured mode.
+func (f *Fluent) encode(entries []fluentEntry) ([]byte, string) {
+	var chunk string
+	if f.c.Ack {
+		var id [16]byte
+		rand.Read(id[:])
+		chunk = base64.StdEncoding.EncodeToString(id[:])
+	}
+	w := &msgpackWriter{}
+	n := 2
+	if chunk != "" || f.c.Mode == FluentPackedForward {
+		n = 3
+	}
+	switch f.c.Mode {
+	case FluentMessage:
+		w.putArray(n + 1)
+		w.putString(entries[0].tag)
+		// [time, record] without the array header
+		w.buf = append(w.buf, entries[0].record[1:]...)
+	case FluentForward:
+		w.putArray(n)
+		w.putString(entries[0].tag)
+		w.putArray(len(entries))
+		for _, e := range entries {
+			w.buf = append(w.buf, e.record...)
+		}
+	case FluentPackedForward:
+		var packed []byte
+		for _, e := range entries {
+			packed = append(packed, e.record...)
+		}
+		w.putArray(n)
+		w.putString(entries[0].tag)
+		w.putBin(packed)
+	}
+	if n == 3 {
+		opts := 0
+		if chunk != "" {
+			opts++
+		}
+		if f.c.Mode == FluentPackedForward {
+			opts++
+		}
+		w.putMap(opts)
+		if f.c.Mode == FluentPackedForward {
+			w.putString("size")
+			w.putInt(int64(len(entries)))
+		}
+		if chunk != "" {
+			w.putString("chunk")
+			w.putString(chunk)
+		}
+	}
+	return w.buf, chunk
+}
+
+// write writes the message and waits for its acknowledgment, if requested.
+func (f *Fluent) write(msg []byte, chunk string) error {
+	if f.conn == nil {
+		conn, err := f.dial()
+		if err != nil {
+			return err
+		}
+		f.conn, f.rd = conn, bufio.NewReader(conn)
+	}
+	f.conn.SetWriteDeadline(time.Now().Add(f.c.Reconnect.WriteTimeout))
+	if _, err := f.conn.Write(msg); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+	f.conn.SetReadDeadline(time.Now().Add(f.c.AckTimeout))
+	resp, err := msgpackRead(f.rd)
+	if err != nil {
+		return err
+	}
+	if m, ok := resp.(map[string]interface{}); !ok || m["ack"] != chunk {
+		return fmt.Errorf("log15: wrong fluent ack %v, expected %s", resp, chunk)
+	}
+	return nil
+}
+
+func (f *Fluent) disconnect() {
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn, f.rd = nil, nil
+	}
+}
+
+// Close sends the buffered records and closes the connection.
+func (f *Fluent) Close() error {
+	err := f.batcher.Close()
+	f.disconnect()
+	return err
+}
+
+func (m muster) FluentHandler(network, addr string, c FluentConfig) *Fluent {
+	h, err := FluentHandler(network, addr, c)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
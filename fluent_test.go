@@ -0,0 +1,192 @@
+package log15
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fluentEvent struct {
+	tag    string
+	record map[string]interface{}
+}
+
+// fluentServer accepts Forward protocol connections and decodes the events.
+// It doesn't acknowledge the first skipAcks chunks.
+func fluentServer(t *testing.T, skipAcks int32) (net.Listener, chan fluentEvent) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan fluentEvent, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				rd := bufio.NewReader(conn)
+				for {
+					msg, err := msgpackRead(rd)
+					if err != nil {
+						return
+					}
+					a := msg.([]interface{})
+					tag := a[0].(string)
+					var entries []interface{}
+					var opts interface{}
+					last, _ := a[len(a)-1].(map[string]interface{})
+					_, packed := last["size"]
+					switch v := a[1].(type) {
+					case []interface{}: // Forward
+						entries = v
+						if len(a) > 2 {
+							opts = a[2]
+						}
+					case []byte:
+						if !packed { // Message
+							entries = []interface{}{a[1:3]}
+							if len(a) > 3 {
+								opts = a[3]
+							}
+							break
+						}
+						// PackedForward
+						brd := bufio.NewReader(bytes.NewReader(v))
+						for {
+							e, err := msgpackRead(brd)
+							if err != nil {
+								break
+							}
+							entries = append(entries, e)
+						}
+						opts = a[2]
+					}
+					for _, e := range entries {
+						e := e.([]interface{})
+						if ts, ok := e[0].([]byte); !ok || len(ts) != 9 || ts[0] != 0 {
+							t.Errorf("wrong EventTime: %v", e[0])
+						}
+						events <- fluentEvent{tag, e[1].(map[string]interface{})}
+					}
+					if m, ok := opts.(map[string]interface{}); ok && m["chunk"] != nil && atomic.AddInt32(&skipAcks, -1) < 0 {
+						w := &msgpackWriter{}
+						w.putMap(1)
+						w.putString("ack")
+						w.putString(m["chunk"].(string))
+						conn.Write(w.buf)
+					}
+				}
+			}()
+		}
+	}()
+	return ln, events
+}
+
+func expectFluentEvents(t *testing.T, events chan fluentEvent, tag string, msgs ...string) {
+	for _, msg := range msgs {
+		select {
+		case e := <-events:
+			if e.tag != tag || e.record["msg"] != msg {
+				t.Fatalf("wrong event %s %v, expected %s %s", e.tag, e.record, tag, msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %s", msg)
+		}
+	}
+}
+
+func TestFluentHandlerModes(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []FluentMode{FluentMessage, FluentForward, FluentPackedForward} {
+		for _, ack := range []bool{false, true} {
+			ln, events := fluentServer(t, 0)
+			h, err := FluentHandler("tcp", ln.Addr().String(), FluentConfig{
+				Mode: mode, Ack: ack, BatchSize: 2, FlushInterval: time.Minute})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := Record{Time: time.Now(), Lvl: LvlInfo, KeyNames: RecordKeyNames{Msg: "msg", Lvl: "lvl"}}
+			for _, msg := range []string{"a", "b", "c"} {
+				r.Msg = msg
+				h.Log(&r)
+			}
+			r.Name, r.Msg = "db", "d"
+			h.Log(&r)
+			if err := h.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			expectFluentEvents(t, events, "log15", "a", "b", "c")
+			expectFluentEvents(t, events, "log15.db", "d")
+			h.Close()
+			ln.Close()
+		}
+	}
+}
+
+func TestFluentHandlerRecord(t *testing.T) {
+	t.Parallel()
+
+	ln, events := fluentServer(t, 0)
+	defer ln.Close()
+	h, err := FluentHandler("tcp", ln.Addr().String(), FluentConfig{Tag: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Log(&Record{
+		Lvl: LvlWarn, Msg: "slow", KeyNames: RecordKeyNames{Msg: "msg", Lvl: "lvl"},
+		Ctx: []interface{}{"ms", 1500, "ratio", 0.5, "ok", true, "user", "bob", errors.New("timeout"),
+			Alone("query", "SELECT 1"), CallerCtx("db.go:7")},
+	})
+	h.Close()
+	select {
+	case e := <-events:
+		for k, v := range map[string]interface{}{
+			"lvl": "warn ", "msg": "slow", "ms": uint64(1500), "ratio": 0.5, "ok": true, "user": "bob",
+			"err": "timeout", "query": "SELECT 1", "caller": "db.go:7",
+		} {
+			if e.record[k] != v {
+				t.Fatalf("wrong %s field: %#v, expected %#v", k, e.record[k], v)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout")
+	}
+}
+
+func TestFluentHandlerAckRetry(t *testing.T) {
+	t.Parallel()
+
+	// the first chunk isn't acknowledged and is resent
+	ln, events := fluentServer(t, 1)
+	defer ln.Close()
+	var errs int32
+	h, err := FluentHandler("tcp", ln.Addr().String(), FluentConfig{Ack: true, AckTimeout: 100 * time.Millisecond,
+		Reconnect: ReconnectConfig{MinBackoff: 10 * time.Millisecond, OnError: func(err error) {
+			atomic.AddInt32(&errs, 1)
+		}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.Log(&Record{Msg: "retried", KeyNames: RecordKeyNames{Msg: "msg"}})
+	h.Flush()
+	expectFluentEvents(t, events, "log15", "retried", "retried")
+	if atomic.LoadInt32(&errs) == 0 {
+		t.Fatalf("expected an ack timeout error")
+	}
+	if h.Dropped() != 0 {
+		t.Fatalf("unexpected dropped records: %d", h.Dropped())
+	}
+
+	if _, err := FluentHandler("udp", ln.Addr().String(), FluentConfig{}); err == nil {
+		t.Fatalf("expected network error")
+	}
+}
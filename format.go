@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -91,7 +92,8 @@ func JsonFormat() Format {
 
 // JsonFormatEx formats log records as JSON objects. If pretty is true,
 // records will be pretty-printed. If lineSeparated is true, records
-// will be logged with a new line between each record.
+// will be logged with a new line between each record. Errors logged
+// without keys are joined under the "err" key.
 func JsonFormatEx(pretty, lineSeparated bool) FormatF {
 	jsonMarshal := json.Marshal
 	if pretty {
@@ -101,21 +103,7 @@ func JsonFormatEx(pretty, lineSeparated bool) FormatF {
 	}
 
 	return func(r *Record) []byte {
-		props := make(map[string]interface{})
-
-		props[r.KeyNames.Time] = r.Time
-		props[r.KeyNames.Lvl] = r.Lvl.String()
-		props[r.KeyNames.Msg] = r.Msg
-
-		for i := 0; i < len(r.Ctx); i += 2 {
-			k, ok := r.Ctx[i].(string)
-			if !ok {
-				props[errorKey] = fmt.Sprintf("%+v is not a string key", r.Ctx[i])
-			}
-			props[k] = formatJSONValue(r.Ctx[i+1])
-		}
-
-		b, err := jsonMarshal(props)
+		b, err := jsonMarshal(jsonProps(r))
 		if err != nil {
 			b, _ = jsonMarshal(map[string]string{
 				errorKey: err.Error(),
@@ -131,6 +119,66 @@ func JsonFormatEx(pretty, lineSeparated bool) FormatF {
 	}
 }
 
+// jsonProps returns the JSON object of the record written by JsonFormatEx.
+func jsonProps(r *Record) map[string]interface{} {
+	props := make(map[string]interface{})
+
+	props[r.KeyNames.Time] = r.Time
+	props[r.KeyNames.Lvl] = r.Lvl.String()
+	props[r.KeyNames.Msg] = r.Msg
+
+	errs := walkCtx(r.Ctx, func(k string, v interface{}) {
+		props[k] = formatJSONValue(v)
+	})
+	if len(errs) > 0 {
+		props["err"] = joinErrors(errs)
+	}
+	return props
+}
+
+// walkCtx calls visit with the key/value pairs of the record context, in order.
+// Alone and Spew values are passed with their titles as keys ("spew" if the Spew
+// message is empty), CallerCtx with the "caller" key and the malformed pairs
+// with errorKey and the error message. Errors logged without keys aren't visited
+// but returned, the handlers join them with joinErrors under the "err" key.
+func walkCtx(ctx []interface{}, visit func(k string, v interface{})) (errs []error) {
+	for i := 0; i < len(ctx); i++ {
+		switch v := ctx[i].(type) {
+		case string:
+			i++
+			if i >= len(ctx) {
+				visit(errorKey, "no value for last key "+v)
+			} else {
+				visit(v, ctx[i])
+			}
+		case error:
+			errs = append(errs, v)
+		case SpewWrapper:
+			if v.Msg == "" {
+				v.Msg = "spew"
+			}
+			visit(v.Msg, v.Obj)
+		case aloneWrapper:
+			visit(v.title, v.obj)
+		case CallerCtx:
+			visit("caller", string(v))
+		case nil:
+		default:
+			visit(errorKey, fmt.Sprintf("%+v is not a string key", v))
+		}
+	}
+	return errs
+}
+
+// joinErrors returns the messages of errs separated by "; ".
+func joinErrors(errs []error) string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
 func formatShared(value interface{}) (result interface{}) {
 	defer func() {
 		if err := recover(); err != nil {
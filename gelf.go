@@ -0,0 +1,230 @@
+package log15
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// GelfFormat formats records as GELF 1.1 messages for Graylog:
+//
+//   - short_message is the record message,
+//   - full_message contains Alone and Spew values and the errors with their
+//     stack traces (for FancyError), printed like the terminal format does,
+//   - level is the syslog severity of the record level,
+//   - context key/value pairs, Alone and Spew values become additional fields,
+//     prefixed with '_'. Keys are sanitized to the allowed characters and "id"
+//     becomes "_id_",
+//   - errors logged without keys are joined in the _err field, the logger name
+//     is the _logger field.
+//
+// If host is empty, os.Hostname() is used. Messages are not terminated.
+func GelfFormat(host string) Format {
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return FormatFunc(func(r *Record) []byte {
+		sev := 7
+		if r.Lvl >= 0 && int(r.Lvl) < len(rfc5424Severity) {
+			sev = rfc5424Severity[r.Lvl]
+		}
+		msg := map[string]interface{}{
+			"version":       "1.1",
+			"host":          host,
+			"short_message": r.Msg,
+			"level":         sev,
+		}
+		if r.Msg == "" {
+			msg["short_message"] = "-"
+		}
+		if !r.Time.IsZero() {
+			msg["timestamp"] = float64(r.Time.UnixNano()/1e6) / 1e3
+		}
+		if r.Name != "" {
+			msg["_logger"] = r.Name
+		}
+		var full bytes.Buffer
+		errs := walkCtx(r.Ctx, func(k string, v interface{}) {
+			msg[gelfFieldName(k)] = formatJSONValue(v)
+		})
+		for _, v := range r.Ctx {
+			switch v := v.(type) {
+			case SpewWrapper:
+				if v.Msg == "" {
+					v.Msg = "spew"
+				}
+				full.WriteString("-------- " + v.Msg + " --------\n")
+				full.WriteString(spew.Sdump(v.Obj))
+			case aloneWrapper:
+				full.WriteString("* " + v.title + ": " + FormatLogfmtValue(v.obj) + "\n")
+			}
+		}
+		for _, err := range errs {
+			full.WriteString(errHeader)
+			full.WriteString(err.Error())
+			if e, ok := err.(FancyError); ok && !e.IsReq() {
+				full.WriteString("\nstacktrace:\n")
+				full.WriteString(e.Stacktrace().String())
+			}
+			full.WriteByte('\n')
+		}
+		if len(errs) > 0 {
+			msg["_err"] = joinErrors(errs)
+		}
+		if full.Len() > 0 {
+			msg["full_message"] = full.String()
+		}
+		b, err := json.Marshal(msg)
+		if err != nil {
+			b, _ = json.Marshal(map[string]interface{}{
+				"version": "1.1", "host": host, "short_message": r.Msg, "level": sev, "_" + errorKey: err.Error(),
+			})
+		}
+		return b
+	})
+}
+
+// gelfFieldName returns the additional field name of the key.
+func gelfFieldName(key string) string {
+	b := []byte(key)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			b[i] = '_'
+		}
+	}
+	if key == "id" {
+		return "_id_"
+	}
+	return "_" + string(b)
+}
+
+// GelfCompression is the compression of GELF UDP messages.
+type GelfCompression int
+
+// List of supported GELF compressions
+const (
+	GelfNoCompression GelfCompression = iota
+	GelfGzip
+	GelfZlib
+)
+
+// GelfConfig configures GelfHandler.
+type GelfConfig struct {
+	// Host is the host field of the messages. Default: os.Hostname().
+	Host string
+	// Compression of UDP messages. TCP messages are never compressed.
+	Compression GelfCompression
+	// ChunkSize is the maximum size of UDP datagrams. Larger messages are split
+	// into chunks. Default: 1420.
+	ChunkSize int
+	// Reconnect configures the TCP connection, see ReconnectingNetHandler.
+	Reconnect ReconnectConfig
+}
+
+const (
+	gelfChunkHeader = 12
+	gelfMaxChunks   = 128
+)
+
+// GelfHandler returns a handler which sends GelfFormat messages to Graylog.
+// The network is udp or tcp. UDP messages are optionally compressed and split
+// into chunks when they are larger than c.ChunkSize. TCP messages are delimited
+// with a null byte and sent with ReconnectingNetHandler.
+func GelfHandler(network, addr string, c GelfConfig) (Handler, error) {
+	fmtr := GelfFormat(c.Host)
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		h, err := ReconnectingNetHandler(network, addr, FormatFunc(func(r *Record) []byte {
+			return append(fmtr.Format(r), 0)
+		}), c.Reconnect)
+		if err != nil {
+			return nil, err
+		}
+		return h, nil
+	case "udp", "udp4", "udp6":
+	default:
+		return nil, errors.New("Wrong `network` value " + network + ", should be udp or tcp")
+	}
+	if c.ChunkSize <= gelfChunkHeader {
+		c.ChunkSize = 1420
+	}
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	h := &gelfUDP{conn: conn, fmtr: fmtr, c: c}
+	if _, err := rand.Read(h.id[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return LazyHandler(h), nil
+}
+
+type gelfUDP struct {
+	conn net.Conn
+	fmtr Format
+	c    GelfConfig
+
+	mu  sync.Mutex
+	id  [8]byte // ID of the last chunked message
+	buf bytes.Buffer
+}
+
+func (h *gelfUDP) Log(r *Record) error {
+	b := h.fmtr.Format(r)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.c.Compression != GelfNoCompression {
+		h.buf.Reset()
+		var w io.WriteCloser
+		if h.c.Compression == GelfGzip {
+			w = gzip.NewWriter(&h.buf)
+		} else {
+			w = zlib.NewWriter(&h.buf)
+		}
+		w.Write(b)
+		w.Close()
+		b = h.buf.Bytes()
+	}
+	if len(b) <= h.c.ChunkSize {
+		_, err := h.conn.Write(b)
+		return err
+	}
+	size := h.c.ChunkSize - gelfChunkHeader
+	n := (len(b) + size - 1) / size
+	if n > gelfMaxChunks {
+		return fmt.Errorf("log15: GELF message of %d bytes needs more than %d chunks", len(b), gelfMaxChunks)
+	}
+	binary.BigEndian.PutUint64(h.id[:], binary.BigEndian.Uint64(h.id[:])+1)
+	chunk := make([]byte, h.c.ChunkSize)
+	chunk[0], chunk[1] = 0x1e, 0x0f
+	copy(chunk[2:10], h.id[:])
+	chunk[11] = byte(n)
+	for i := 0; i < n; i++ {
+		chunk[10] = byte(i)
+		m := copy(chunk[gelfChunkHeader:], b[i*size:])
+		if _, err := h.conn.Write(chunk[:gelfChunkHeader+m]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *gelfUDP) Close() error {
+	return h.conn.Close()
+}
+
+func (m muster) GelfHandler(network, addr string, c GelfConfig) Handler {
+	return must(GelfHandler(network, addr, c))
+}
@@ -0,0 +1,157 @@
+package log15
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGelfFormat(t *testing.T) {
+	t.Parallel()
+
+	r := &Record{
+		Time: time.Unix(1500000000, 123456789),
+		Lvl:  LvlError,
+		Msg:  "payment failed",
+		Ctx: []interface{}{"id", 7, "user name", "bob", errors.New("declined"),
+			Alone("request", "POST /pay"), CallerCtx("pay.go:12")},
+		Name: "shop",
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(GelfFormat("web1").Format(r), &m); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]interface{}{
+		"version": "1.1", "host": "web1", "short_message": "payment failed", "level": 3.0,
+		"timestamp": 1500000000.123, "_id_": 7.0, "_user_name": "bob", "_err": "declined",
+		"_logger": "shop", "_caller": "pay.go:12",
+	} {
+		if m[k] != v {
+			t.Fatalf("wrong %s field: %v, expected %v", k, m[k], v)
+		}
+	}
+	full, _ := m["full_message"].(string)
+	if !strings.Contains(full, "declined") || !strings.Contains(full, `* request: "POST /pay"`) {
+		t.Fatalf("wrong full_message: %q", full)
+	}
+}
+
+// readGelfUDP reads a GELF message from the UDP socket, joining the chunks.
+func readGelfUDP(t *testing.T, conn net.PacketConn) []byte {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var chunks [][]byte
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := append([]byte(nil), buf[:n]...)
+		if b[0] != 0x1e || b[1] != 0x0f {
+			return b
+		}
+		if chunks == nil {
+			chunks = make([][]byte, b[11])
+		}
+		chunks[b[10]] = b[12:]
+		if int(b[10]) == len(chunks)-1 {
+			return bytes.Join(chunks, nil)
+		}
+	}
+}
+
+func TestGelfHandlerUDP(t *testing.T) {
+	t.Parallel()
+
+	srv, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	big := strings.Repeat("abcdefghij", 500)
+	for _, tc := range []struct {
+		c      GelfConfig
+		decode func(io.Reader) (io.Reader, error)
+	}{
+		{GelfConfig{Host: "h", ChunkSize: 100}, func(r io.Reader) (io.Reader, error) { return r, nil }},
+		{GelfConfig{Host: "h", ChunkSize: 100, Compression: GelfGzip}, func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{GelfConfig{Host: "h", ChunkSize: 100, Compression: GelfZlib}, func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+	} {
+		h, err := GelfHandler("udp", srv.LocalAddr().String(), tc.c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, msg := range []string{"small", big} {
+			if err := h.Log(&Record{Lvl: LvlInfo, Msg: msg}); err != nil {
+				t.Fatal(err)
+			}
+			rd, err := tc.decode(bytes.NewReader(readGelfUDP(t, srv)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var m map[string]interface{}
+			if err := json.NewDecoder(rd).Decode(&m); err != nil {
+				t.Fatal(err)
+			}
+			if m["short_message"] != msg {
+				t.Fatalf("wrong message of %d bytes, compression %d", len(msg), tc.c.Compression)
+			}
+		}
+		TryClose(h)
+	}
+
+	h, _ := GelfHandler("udp", srv.LocalAddr().String(), GelfConfig{ChunkSize: 20})
+	if err := h.Log(&Record{Msg: big}); err == nil {
+		t.Fatalf("expected too many chunks error")
+	}
+	TryClose(h)
+}
+
+func TestGelfHandlerTCP(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	msgs := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		for {
+			b, err := br.ReadBytes(0)
+			if err != nil {
+				return
+			}
+			msgs <- string(b[:len(b)-1])
+		}
+	}()
+
+	h, err := GelfHandler("tcp", ln.Addr().String(), GelfConfig{Host: "h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer TryClose(h)
+	h.Log(&Record{Lvl: LvlWarn, Msg: "one"})
+	h.Log(&Record{Lvl: LvlWarn, Msg: "two"})
+	expectLines(t, msgs, `{"host":"h","level":4,"short_message":"one","version":"1.1"}`,
+		`{"host":"h","level":4,"short_message":"two","version":"1.1"}`)
+
+	if _, err := GelfHandler("unix", "/tmp/x", GelfConfig{}); err == nil {
+		t.Fatalf("expected network error")
+	}
+}
@@ -12,4 +12,5 @@ require (
 	github.com/stvp/rollbar v0.5.1
 	golang.org/x/sys v0.0.0-20200302150141-5c8b2ff67527
 	gopkg.in/check.v1 v1.0.0-20200227125254-8fa46927fb4f
+	gopkg.in/yaml.v2 v2.2.8
 )
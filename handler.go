@@ -1,6 +1,8 @@
 package log15
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -19,6 +21,59 @@ type Handler interface {
 	Log(r *Record) error
 }
 
+// Closer is implemented by handlers which hold resources like files, sockets
+// or goroutines. Close writes out all pending records and releases
+// the resources. Handlers which wrap other handlers propagate Close to them.
+type Closer interface {
+	Handler
+	Close() error
+}
+
+// Flusher is implemented by handlers which buffer records. Flush blocks until
+// all records logged before the call are written out. Handlers which wrap other
+// handlers propagate Flush to them.
+type Flusher interface {
+	Handler
+	Flush() error
+}
+
+// ErrHandlerClosed is returned when logging to a handler which was already closed.
+var ErrHandlerClosed = errors.New("log15: handler is closed")
+
+// TryClose closes h if it implements Closer, otherwise it does nothing.
+func TryClose(h Handler) error {
+	if c, ok := h.(Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// TryFlush flushes h if it implements Flusher, otherwise it does nothing.
+func TryFlush(h Handler) error {
+	if f, ok := h.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// LvlChecker is implemented by handlers which can tell upfront that they will
+// discard records of a given level. Loggers use it to skip creating such records.
+// Handlers which change record levels (eg: ext.EscalateErrHandler) must not
+// pass Enabled calls to the handlers they wrap.
+type LvlChecker interface {
+	Handler
+	Enabled(lvl Lvl) bool
+}
+
+// LvlEnabled reports whether h may write records of the given level.
+// It returns true if h doesn't implement LvlChecker.
+func LvlEnabled(h Handler, lvl Lvl) bool {
+	if c, ok := h.(LvlChecker); ok {
+		return c.Enabled(lvl)
+	}
+	return true
+}
+
 // FuncHandler returns a Handler that logs records with the given
 // function.
 func FuncHandler(fn func(r *Record) error) Handler {
@@ -34,6 +89,68 @@ func (h FuncHandlerT) Log(r *Record) error {
 	return h(r)
 }
 
+// ParentFuncHandler returns a Handler that logs records with the given
+// function and propagates Close and Flush to the children handlers.
+// Use it to write handlers which wrap other handlers.
+func ParentFuncHandler(fn func(r *Record) error, children ...Handler) Handler {
+	return parentHandler{fn, children}
+}
+
+// parentHandler is a FuncHandlerT which wraps other handlers.
+type parentHandler struct {
+	FuncHandlerT
+	children []Handler
+}
+
+// Close closes all children handlers and returns the first error.
+func (h parentHandler) Close() error {
+	var err error
+	for _, c := range h.children {
+		if cerr := TryClose(c); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Flush flushes all children handlers and returns the first error.
+func (h parentHandler) Flush() error {
+	var err error
+	for _, c := range h.children {
+		if ferr := TryFlush(c); err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+// PassFuncHandler returns a Handler like ParentFuncHandler, for handlers which
+// don't change record levels. It also implements LvlChecker: it's enabled for
+// a level when any of the children is.
+func PassFuncHandler(fn func(r *Record) error, children ...Handler) Handler {
+	return passFuncHandler(fn, children...)
+}
+
+// passFuncHandler returns a parent handler which doesn't change record levels.
+func passFuncHandler(fn func(r *Record) error, children ...Handler) passHandler {
+	return passHandler{parentHandler{fn, children}}
+}
+
+// passHandler is a parentHandler which doesn't change record levels,
+// so it's enabled for a level when any of its children is.
+type passHandler struct {
+	parentHandler
+}
+
+func (h passHandler) Enabled(lvl Lvl) bool {
+	for _, c := range h.children {
+		if LvlEnabled(c, lvl) {
+			return true
+		}
+	}
+	return false
+}
+
 // StreamHandler writes log records to an io.Writer
 // with the given format. StreamHandler can be used
 // to easily begin writing log records to other
@@ -41,24 +158,64 @@ func (h FuncHandlerT) Log(r *Record) error {
 //
 // StreamHandler wraps itself with LazyHandler and SyncHandler
 // to evaluate Lazy objects and perform safe concurrent writes.
+// StreamHandler doesn't close the writer. Flush is passed to the writer
+// if it implements `Flush() error` (eg: bufio.Writer).
 func StreamHandler(wr io.Writer, fmtr Format) Handler {
-	h := FuncHandler(func(r *Record) error {
-		_, err := wr.Write(fmtr.Format(r))
-		return err
-	})
-	return LazyHandler(SyncHandler(h))
+	return LazyHandler(SyncHandler(streamHandler{wr, fmtr}))
+}
+
+type streamHandler struct {
+	wr   io.Writer
+	fmtr Format
+}
+
+func (h streamHandler) Log(r *Record) error {
+	_, err := h.wr.Write(h.fmtr.Format(r))
+	return err
+}
+
+func (h streamHandler) Flush() error {
+	if f, ok := h.wr.(interface {
+		Flush() error
+	}); ok {
+		return f.Flush()
+	}
+	return nil
 }
 
 // SyncHandler can be wrapped around a handler to guarantee that
 // only a single Log operation can proceed at a time. It's necessary
-// for thread-safe concurrent writes.
+// for thread-safe concurrent writes. Close and Flush are serialized
+// with Log operations as well.
 func SyncHandler(h Handler) Handler {
-	var mu sync.Mutex
-	return FuncHandler(func(r *Record) error {
-		defer mu.Unlock()
-		mu.Lock()
-		return h.Log(r)
-	})
+	return &syncHandler{h: h}
+}
+
+type syncHandler struct {
+	mu sync.Mutex
+	h  Handler
+}
+
+func (h *syncHandler) Log(r *Record) error {
+	defer h.mu.Unlock()
+	h.mu.Lock()
+	return h.h.Log(r)
+}
+
+func (h *syncHandler) Enabled(lvl Lvl) bool {
+	return LvlEnabled(h.h, lvl)
+}
+
+func (h *syncHandler) Close() error {
+	defer h.mu.Unlock()
+	h.mu.Lock()
+	return TryClose(h.h)
+}
+
+func (h *syncHandler) Flush() error {
+	defer h.mu.Unlock()
+	h.mu.Lock()
+	return TryFlush(h.h)
 }
 
 // FileHandler returns a handler which writes log records to the give file
@@ -70,7 +227,7 @@ func FileHandler(path string, fmtr Format) (Handler, error) {
 	if err != nil {
 		return nil, err
 	}
-	return closingHandler{f, StreamHandler(f, fmtr)}, nil
+	return &closingHandler{f, StreamHandler(f, fmtr)}, nil
 }
 
 // NetHandler opens a socket to the given address and writes records
@@ -81,19 +238,43 @@ func NetHandler(network, addr string, fmtr Format) (Handler, error) {
 		return nil, err
 	}
 
-	return closingHandler{conn, StreamHandler(conn, fmtr)}, nil
+	return &closingHandler{conn, StreamHandler(conn, fmtr)}, nil
+}
+
+// TLSNetHandler opens a TLS connection to the given address and writes records
+// over it, like NetHandler. The config sets the client certificates, the trusted
+// CAs and the server name. Use ReconnectingNetHandler with ReconnectConfig.TLSConfig
+// for a connection which survives network failures.
+func TLSNetHandler(network, addr string, config *tls.Config, fmtr Format) (Handler, error) {
+	conn, err := tls.Dial(network, addr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &closingHandler{conn, StreamHandler(conn, fmtr)}, nil
 }
 
-// XXX: closingHandler is essentially unused at the moment
-// it's meant for a future time when the Handler interface supports
-// a possible Close() operation
+// closingHandler is a Handler which owns the writer of the wrapped handler.
+// Close closes the wrapped handler first and then the writer.
 type closingHandler struct {
 	io.WriteCloser
 	Handler
 }
 
+func (h *closingHandler) Enabled(lvl Lvl) bool {
+	return LvlEnabled(h.Handler, lvl)
+}
+
+func (h *closingHandler) Flush() error {
+	return TryFlush(h.Handler)
+}
+
 func (h *closingHandler) Close() error {
-	return h.WriteCloser.Close()
+	err := TryClose(h.Handler)
+	if cerr := h.WriteCloser.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 // FilterHandler returns a Handler that only writes records to the
@@ -110,12 +291,12 @@ func (h *closingHandler) Close() error {
 //    }, h))
 //
 func FilterHandler(fn func(r *Record) bool, h Handler) Handler {
-	return FuncHandler(func(r *Record) error {
+	return passFuncHandler(func(r *Record) error {
 		if fn(r) {
 			return h.Log(r)
 		}
 		return nil
-	})
+	}, h)
 }
 
 // MatchFilterHandler returns a Handler that only writes records
@@ -153,9 +334,18 @@ func MatchFilterHandler(key string, value interface{}, h Handler) Handler {
 //     log.LvlFilterHandler(log.LvlError, log.StdoutHandler)
 //
 func LvlFilterHandler(maxLvl Lvl, h Handler) Handler {
-	return FilterHandler(func(r *Record) (pass bool) {
+	return lvlFilterHandler{FilterHandler(func(r *Record) (pass bool) {
 		return r.Lvl <= maxLvl
-	}, h)
+	}, h).(passHandler), maxLvl}
+}
+
+type lvlFilterHandler struct {
+	passHandler
+	maxLvl Lvl
+}
+
+func (h lvlFilterHandler) Enabled(lvl Lvl) bool {
+	return lvl <= h.maxLvl && h.passHandler.Enabled(lvl)
 }
 
 // MultiHandler dispatches any write to each of its handlers.
@@ -168,13 +358,13 @@ func LvlFilterHandler(maxLvl Lvl, h Handler) Handler {
 //         log.StderrHandler)
 //
 func MultiHandler(hs ...Handler) Handler {
-	return FuncHandler(func(r *Record) error {
+	return passFuncHandler(func(r *Record) error {
 		for _, h := range hs {
 			// what to do about failures?
 			h.Log(r)
 		}
 		return nil
-	})
+	}, hs...)
 }
 
 // FailoverHandler writes all log records to the first handler
@@ -193,7 +383,7 @@ func MultiHandler(hs ...Handler) Handler {
 // the form "failover_err_{idx}" which explain the error encountered while
 // trying to write to the handlers before them in the list.
 func FailoverHandler(hs ...Handler) Handler {
-	return FuncHandler(func(r *Record) error {
+	return passFuncHandler(func(r *Record) error {
 		var err error
 		for i, h := range hs {
 			err = h.Log(r)
@@ -203,12 +393,13 @@ func FailoverHandler(hs ...Handler) Handler {
 			r.Ctx = append(r.Ctx, fmt.Sprintf("failover_err_%d", i), err)
 		}
 		return err
-	})
+	}, hs...)
 }
 
 // ChannelHandler writes all records to the given channel.
 // It blocks if the channel is full. Useful for async processing
-// of log messages, it's used by BufferedHandler.
+// of log messages. See BufferedHandlerEx for an asynchronous handler
+// with overflow policies.
 func ChannelHandler(recs chan<- *Record) Handler {
 	return FuncHandler(func(r *Record) error {
 		recs <- r
@@ -216,27 +407,12 @@ func ChannelHandler(recs chan<- *Record) Handler {
 	})
 }
 
-// BufferedHandler writes all records to a buffered
-// channel of the given size which flushes into the wrapped
-// handler whenever it is available for writing. Since these
-// writes happen asynchronously, all writes to a BufferedHandler
-// never return an error and any errors from the wrapped handler are ignored.
-func BufferedHandler(bufSize int, h Handler) Handler {
-	recs := make(chan *Record, bufSize)
-	go func() {
-		for m := range recs {
-			_ = h.Log(m)
-		}
-	}()
-	return ChannelHandler(recs)
-}
-
 // LazyHandler writes all values to the wrapped handler after evaluating
 // any lazy functions in the record's context. It is already wrapped
 // around StreamHandler and SyslogHandler in this library, you'll only need
 // it if you write your own Handler.
 func LazyHandler(h Handler) Handler {
-	return FuncHandler(func(r *Record) error {
+	return passFuncHandler(func(r *Record) error {
 		// go through the values (odd indices) and reassign
 		// the values of any lazy fn to the result of its execution
 		hadErr := false
@@ -261,7 +437,7 @@ func LazyHandler(h Handler) Handler {
 		}
 
 		return h.Log(r)
-	})
+	}, h)
 }
 
 func evaluateLazy(lz Lazy) (interface{}, error) {
@@ -295,14 +471,25 @@ func evaluateLazy(lz Lazy) (interface{}, error) {
 // It is useful for dynamically disabling logging at runtime via
 // a Logger's SetHandler method.
 func DiscardHandler() Handler {
-	return FuncHandler(func(r *Record) error {
-		return nil
-	})
+	return discardHandler{}
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Log(r *Record) error {
+	return nil
+}
+
+func (discardHandler) Enabled(lvl Lvl) bool {
+	return false
 }
 
-// Must object provides the following Handler creation functions
-// which instead of returning an error parameter only return a Handler
-// and panic on failure: FileHandler, NetHandler, SyslogHandler, SyslogNetHandler
+// Must object provides the following handler constructors which instead of
+// returning an error parameter only return the handler, of the same type as
+// the wrapped function does, and panic on failure: FileHandler, RotatingFileHandler,
+// NetHandler, TLSNetHandler, ReconnectingNetHandler, SyslogHandler, SyslogNetHandler,
+// SyslogTLSHandler, RFC5424Handler, JournalHandler, GelfHandler, FluentHandler,
+// LokiHandler, ElasticHandler, OTLPHandler
 var Must muster
 
 func must(h Handler, err error) Handler {
@@ -321,3 +508,7 @@ func (m muster) FileHandler(path string, fmtr Format) Handler {
 func (m muster) NetHandler(network, addr string, fmtr Format) Handler {
 	return must(NetHandler(network, addr, fmtr))
 }
+
+func (m muster) TLSNetHandler(network, addr string, config *tls.Config, fmtr Format) Handler {
+	return must(TLSNetHandler(network, addr, config, fmtr))
+}
@@ -24,3 +24,18 @@ func (h *swapHandler) Get() Handler {
 func (h *swapHandler) Swap(newHandler Handler) {
 	atomic.StorePointer(&h.handler, unsafe.Pointer(&newHandler))
 }
+
+// Close closes the current handler.
+func (h *swapHandler) Close() error {
+	return TryClose(h.Get())
+}
+
+// Flush flushes the current handler.
+func (h *swapHandler) Flush() error {
+	return TryFlush(h.Get())
+}
+
+// Enabled reports whether the current handler is enabled for the level.
+func (h *swapHandler) Enabled(lvl Lvl) bool {
+	return LvlEnabled(h.Get(), lvl)
+}
@@ -21,3 +21,18 @@ func (h *swapHandler) Swap(newHandler Handler) {
 func (h *swapHandler) Get() Handler {
 	return *h.handler.Load().(*Handler)
 }
+
+// Close closes the current handler.
+func (h *swapHandler) Close() error {
+	return TryClose(h.Get())
+}
+
+// Flush flushes the current handler.
+func (h *swapHandler) Flush() error {
+	return TryFlush(h.Get())
+}
+
+// Enabled reports whether the current handler is enabled for the level.
+func (h *swapHandler) Enabled(lvl Lvl) bool {
+	return LvlEnabled(h.Get(), lvl)
+}
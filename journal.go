@@ -0,0 +1,215 @@
+//go:build linux
+// +build linux
+
+package log15
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// JournalSocket is the default path of the journald native protocol socket.
+const JournalSocket = "/run/systemd/journal/socket"
+
+// JournalConfig configures JournalHandler.
+type JournalConfig struct {
+	// Path of the journald socket. Default: JournalSocket.
+	Path string
+	// Identifier is the SYSLOG_IDENTIFIER field. Default: the program name.
+	Identifier string
+	// Fields are added to every entry. Keys must be valid journal field names.
+	Fields map[string]string
+}
+
+// JournalHandler returns a handler which sends records to systemd-journald using
+// its native protocol. The entries have the following fields:
+//
+//     MESSAGE            record message
+//     PRIORITY           syslog severity of the record level
+//     CODE_FILE, CODE_LINE, CODE_FUNC  the logging call site
+//     SYSLOG_IDENTIFIER  c.Identifier
+//     LOGGER             logger name, if it's set
+//
+// Context keys are uppercased and characters not allowed in journal field names
+// are replaced with '_' (eg: "req.id" becomes REQ_ID). Keys which clash with
+// the fields above get the CTX_ prefix (eg: "logger" becomes CTX_LOGGER). Errors
+// logged without keys are sent as ERR. Entries too large for a datagram are passed to journald
+// in a sealed memory file.
+func JournalHandler(c JournalConfig) (*Journal, error) {
+	if c.Path == "" {
+		c.Path = JournalSocket
+	}
+	if c.Identifier == "" {
+		c.Identifier = filepath.Base(os.Args[0])
+	}
+	// an unconnected socket, which can send both data and file descriptors
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	var common bytes.Buffer
+	appendJournalField(&common, "SYSLOG_IDENTIFIER", c.Identifier)
+	for k, v := range c.Fields {
+		appendJournalField(&common, journalFieldName(k), v)
+	}
+	j := &Journal{conn: conn, addr: &net.UnixAddr{Name: c.Path, Net: "unixgram"}, common: common.Bytes()}
+	j.lazy = LazyHandler(FuncHandler(j.write))
+	return j, nil
+}
+
+// Journal is the Handler returned by JournalHandler.
+type Journal struct {
+	conn   *net.UnixConn
+	addr   *net.UnixAddr
+	common []byte // encoded fields sent with each entry
+	lazy   Handler
+}
+
+// Log implements Handler interface.
+func (j *Journal) Log(r *Record) error {
+	return j.lazy.Log(r)
+}
+
+func (j *Journal) write(r *Record) error {
+	var buf bytes.Buffer
+	appendJournalField(&buf, "MESSAGE", r.Msg)
+	sev := 7
+	if r.Lvl >= 0 && int(r.Lvl) < len(rfc5424Severity) {
+		sev = rfc5424Severity[r.Lvl]
+	}
+	appendJournalField(&buf, "PRIORITY", strconv.Itoa(sev))
+	if f := r.Call.Frame(); f.PC != 0 {
+		appendJournalField(&buf, "CODE_FILE", f.File)
+		appendJournalField(&buf, "CODE_LINE", strconv.Itoa(f.Line))
+		appendJournalField(&buf, "CODE_FUNC", f.Function)
+	}
+	if r.Name != "" {
+		appendJournalField(&buf, "LOGGER", r.Name)
+	}
+	buf.Write(j.common)
+	errs := walkCtx(r.Ctx, func(k string, v interface{}) {
+		appendJournalField(&buf, journalCtxName(k), fmt.Sprint(formatJSONValue(v)))
+	})
+	if len(errs) > 0 {
+		appendJournalField(&buf, "ERR", joinErrors(errs))
+	}
+	return j.send(buf.Bytes())
+}
+
+func (j *Journal) send(b []byte) error {
+	_, err := j.conn.WriteToUnix(b, j.addr)
+	if err == nil || !isMsgTooLong(err) {
+		return err
+	}
+	// the entry doesn't fit a datagram, pass it in a sealed memory file
+	f, err := journalMemFile()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(b); err != nil {
+		return err
+	}
+	// sealing fails for the /dev/shm files, which journald accepts as well
+	unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL)
+	_, _, err = j.conn.WriteMsgUnix(nil, unix.UnixRights(int(f.Fd())), j.addr)
+	return err
+}
+
+func isMsgTooLong(err error) bool {
+	if oerr, ok := err.(*net.OpError); ok {
+		err = oerr.Err
+	}
+	if serr, ok := err.(*os.SyscallError); ok {
+		err = serr.Err
+	}
+	return err == syscall.EMSGSIZE || err == syscall.ENOBUFS
+}
+
+// journalMemFile creates an anonymous memory file, or an unlinked file in /dev/shm
+// on kernels without memfd_create.
+func journalMemFile() (*os.File, error) {
+	fd, err := unix.MemfdCreate("log15-journal", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err == nil {
+		return os.NewFile(uintptr(fd), "log15-journal"), nil
+	}
+	f, err := ioutil.TempFile("/dev/shm", "log15-journal-")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(f.Name())
+	return f, nil
+}
+
+// Close closes the journald socket.
+func (j *Journal) Close() error {
+	return j.conn.Close()
+}
+
+// appendJournalField encodes the field in the journald native protocol.
+func appendJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+	} else {
+		// values with new lines are sent with their length
+		buf.WriteByte('\n')
+		binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+		buf.WriteString(value)
+	}
+	buf.WriteByte('\n')
+}
+
+// journalFieldName converts the key to a valid journal field name: up to 64
+// upper case letters, digits and underscores, not starting with an underscore
+// or a digit.
+func journalFieldName(key string) string {
+	b := []byte(strings.ToUpper(key))
+	for i, c := range b {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+	name := strings.TrimLeft(string(b), "_0123456789")
+	if name == "" {
+		name = "FIELD"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// journalCtxName converts the context key to a journal field name which
+// doesn't clash with the fields set by the handler.
+func journalCtxName(key string) string {
+	name := journalFieldName(key)
+	switch {
+	case name == "MESSAGE", name == "PRIORITY", name == "SYSLOG_IDENTIFIER", name == "LOGGER",
+		strings.HasPrefix(name, "CODE_"):
+		name = "CTX_" + name
+		if len(name) > 64 {
+			name = name[:64]
+		}
+	}
+	return name
+}
+
+func (m muster) JournalHandler(c JournalConfig) *Journal {
+	h, err := JournalHandler(c)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
@@ -0,0 +1,159 @@
+// +build linux
+
+package log15
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// parseJournalEntry decodes the journald native protocol.
+func parseJournalEntry(t *testing.T, b []byte) map[string]string {
+	fields := map[string]string{}
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "=\n")
+		if i < 0 {
+			t.Fatalf("malformed entry: %q", b)
+		}
+		name := string(b[:i])
+		if b[i] == '=' {
+			end := bytes.IndexByte(b, '\n')
+			fields[name] = string(b[i+1 : end])
+			b = b[end+1:]
+			continue
+		}
+		n := binary.LittleEndian.Uint64(b[i+1 : i+9])
+		fields[name] = string(b[i+9 : i+9+int(n)])
+		b = b[i+9+int(n)+1:]
+	}
+	return fields
+}
+
+func journalTest(t *testing.T) (*net.UnixConn, *Journal, func()) {
+	dir, err := ioutil.TempDir("", "log15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(dir, "journal.sock")
+	srv, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := JournalHandler(JournalConfig{Path: sock, Identifier: "app", Fields: map[string]string{"env": "test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return srv, j, func() {
+		j.Close()
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestJournalHandler(t *testing.T) {
+	t.Parallel()
+
+	srv, j, done := journalTest(t)
+	defer done()
+	l := New("req.id", 7)
+	l.SetHandler(j)
+	l.Warn("disk\nfull", "free_mb", 12, errors.New("ENOSPC"))
+
+	buf := make([]byte, 64*1024)
+	n, err := srv.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := parseJournalEntry(t, buf[:n])
+	for k, v := range map[string]string{
+		"MESSAGE": "disk\nfull", "PRIORITY": "4", "SYSLOG_IDENTIFIER": "app", "ENV": "test",
+		"REQ_ID": "7", "FREE_MB": "12", "ERR": "ENOSPC", "CODE_FUNC": "github.com/robert-zaremba/log15.TestJournalHandler",
+	} {
+		if f[k] != v {
+			t.Fatalf("wrong %s field: %q, expected %q", k, f[k], v)
+		}
+	}
+	if !strings.HasSuffix(f["CODE_FILE"], "journal_test.go") || f["CODE_LINE"] == "" {
+		t.Fatalf("wrong code location: %s:%s", f["CODE_FILE"], f["CODE_LINE"])
+	}
+}
+
+func TestJournalHandlerReservedKeys(t *testing.T) {
+	t.Parallel()
+
+	srv, j, done := journalTest(t)
+	defer done()
+	j.Log(&Record{Lvl: LvlInfo, Msg: "login", Name: "auth", Ctx: []interface{}{
+		"message", "hi", "priority", 1, "logger", "x", "code.file", "a.go", "syslog_identifier", "y"}})
+
+	buf := make([]byte, 64*1024)
+	n, err := srv.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := parseJournalEntry(t, buf[:n])
+	for k, v := range map[string]string{
+		"MESSAGE": "login", "PRIORITY": "6", "LOGGER": "auth", "SYSLOG_IDENTIFIER": "app",
+		"CTX_MESSAGE": "hi", "CTX_PRIORITY": "1", "CTX_LOGGER": "x", "CTX_CODE_FILE": "a.go", "CTX_SYSLOG_IDENTIFIER": "y",
+	} {
+		if f[k] != v {
+			t.Fatalf("wrong %s field: %q, expected %q", k, f[k], v)
+		}
+	}
+}
+
+func TestJournalHandlerLargeEntry(t *testing.T) {
+	t.Parallel()
+
+	srv, j, done := journalTest(t)
+	defer done()
+	big := strings.Repeat("x", 4<<20)
+	if err := j.Log(&Record{Lvl: LvlInfo, Msg: "big", Ctx: []interface{}{"payload", big}}); err != nil {
+		t.Fatal(err)
+	}
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := srv.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected a control message, got %v %v", msgs, err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("expected a file descriptor, got %v %v", fds, err)
+	}
+	mf := os.NewFile(uintptr(fds[0]), "memfd")
+	defer mf.Close()
+	mf.Seek(0, 0)
+	b, err := ioutil.ReadAll(mf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := parseJournalEntry(t, b)
+	if f["MESSAGE"] != "big" || f["PAYLOAD"] != big {
+		t.Fatalf("wrong entry: MESSAGE=%q, len(PAYLOAD)=%d", f["MESSAGE"], len(f["PAYLOAD"]))
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	t.Parallel()
+
+	for k, v := range map[string]string{"req.id": "REQ_ID", "_secret": "SECRET", "9lives": "LIVES", "élan": "LAN", "": "FIELD"} {
+		if n := journalFieldName(k); n != v {
+			t.Fatalf("wrong field name for %q: %q, expected %q", k, n, v)
+		}
+	}
+}
@@ -3,10 +3,13 @@ package log15
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
 	"regexp"
 	"runtime"
 	"sync"
@@ -115,6 +118,34 @@ func TestJson(t *testing.T) {
 	validate("lvl", "error")
 }
 
+func TestWalkCtx(t *testing.T) {
+	t.Parallel()
+
+	e1, e2 := errors.New("e1"), errors.New("e2")
+	var visited []interface{}
+	errs := walkCtx([]interface{}{"x", 1, e1, Spew(2), Spew(3, "s"), Alone("a", 4), CallerCtx("c.go:1"),
+		nil, 5, 6, e2, "last"}, func(k string, v interface{}) {
+		visited = append(visited, k, v)
+	})
+	expected := []interface{}{"x", 1, "spew", 2, "s", 3, "a", 4, "caller", "c.go:1",
+		errorKey, "5 is not a string key", errorKey, "6 is not a string key", errorKey, "no value for last key last"}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Fatalf("wrong pairs %v, expected %v", visited, expected)
+	}
+	if len(errs) != 2 || joinErrors(errs) != "e1; e2" {
+		t.Fatalf("wrong errors %v", errs)
+	}
+
+	// the handlers join the keyless errors the same way
+	r := &Record{Msg: "m", Ctx: []interface{}{e1, e2}, KeyNames: RecordKeyNames{Time: "t", Lvl: "lvl", Msg: "msg"}}
+	if b := RFC5424Format(RFC5424Config{}).Format(r); !bytes.Contains(b, []byte(`err="e1; e2"`)) {
+		t.Fatalf("wrong RFC 5424 errors: %s", b)
+	}
+	if props := jsonProps(r); props["err"] != "e1; e2" {
+		t.Fatalf("wrong JSON errors: %v", props["err"])
+	}
+}
+
 type testtype struct {
 	name string
 }
@@ -533,3 +564,190 @@ func TestConcurrent(t *testing.T) {
 		}
 	}
 }
+
+type closeRecorder struct {
+	logged  int
+	flushed int
+	closed  int
+}
+
+func (h *closeRecorder) Log(r *Record) error {
+	h.logged++
+	return nil
+}
+
+func (h *closeRecorder) Flush() error {
+	h.flushed++
+	return nil
+}
+
+func (h *closeRecorder) Close() error {
+	h.closed++
+	return nil
+}
+
+func TestCloseHandlerTree(t *testing.T) {
+	t.Parallel()
+
+	h1, h2, h3 := &closeRecorder{}, &closeRecorder{}, &closeRecorder{}
+	h := LvlFilterHandler(LvlInfo, MultiHandler(
+		SyncHandler(h1),
+		FailoverHandler(h2, PassFuncHandler(CallerFileHandler(h3, true), h3))))
+
+	if err := TryFlush(h); err != nil {
+		t.Fatalf("unexpected flush error: %v", err)
+	}
+	if err := TryClose(h); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	for i, r := range []*closeRecorder{h1, h2, h3} {
+		if r.flushed != 1 || r.closed != 1 {
+			t.Fatalf("handler %d: expected 1 flush and 1 close, got %d and %d", i, r.flushed, r.closed)
+		}
+	}
+}
+
+func TestFileHandlerClose(t *testing.T) {
+	t.Parallel()
+
+	f, err := ioutil.TempFile("", "log15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	h, err := FileHandler(f.Name(), LogfmtFormat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New()
+	l.SetHandler(h)
+	l.Info("before close")
+	if err = TryClose(h); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	if err = h.Log(&Record{Msg: "after close"}); err == nil {
+		t.Fatalf("expected an error when writing to a closed file")
+	}
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte("before close")) {
+		t.Fatalf("expected the record in the file, got: %q", b)
+	}
+}
+
+func TestBufferedHandlerClose(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var msgs []string
+	h := &closeRecorder{}
+	b := BufferedHandler(100, MultiHandler(h, FuncHandler(func(r *Record) error {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		msgs = append(msgs, r.Msg)
+		mu.Unlock()
+		return nil
+	})))
+	l := New()
+	l.SetHandler(b)
+	for i := 0; i < 10; i++ {
+		l.Info("msg")
+	}
+	if err := TryFlush(b); err != nil {
+		t.Fatalf("unexpected flush error: %v", err)
+	}
+	mu.Lock()
+	if len(msgs) != 10 {
+		t.Fatalf("expected all records written after flush, got %d", len(msgs))
+	}
+	mu.Unlock()
+
+	l.Info("last")
+	if err := TryClose(b); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	if h.logged != 11 || h.flushed != 1 || h.closed != 1 {
+		t.Fatalf("wrong wrapped handler state: %+v", *h)
+	}
+	if err := b.Log(&Record{}); err != ErrHandlerClosed {
+		t.Fatalf("expected ErrHandlerClosed, got %v", err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	old := root.GetHandler()
+	defer root.SetHandler(old)
+
+	h := &closeRecorder{}
+	root.SetHandler(h)
+	l := New("child", 1)
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+	if h.closed != 1 {
+		t.Fatalf("expected root handler to be closed")
+	}
+	l.Error("dropped")
+	if h.logged != 0 {
+		t.Fatalf("expected records logged after Shutdown to be discarded")
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	root.SetHandler(ParentFuncHandler(h.Log, FuncHandlerT(h.Log), closeFunc(func() error {
+		<-block
+		return nil
+	})))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+}
+
+type closeFunc func() error
+
+func (f closeFunc) Log(r *Record) error { return nil }
+func (f closeFunc) Close() error        { return f() }
+
+func TestEnabled(t *testing.T) {
+	t.Parallel()
+
+	h, r := testHandler()
+	l := New()
+	l.SetHandler(MultiHandler(
+		LvlFilterHandler(LvlWarn, h),
+		LazyHandler(LvlFilterHandler(LvlInfo, DiscardHandler()))))
+	child := l.New("k", "v")
+
+	for lvl, expected := range map[Lvl]bool{LvlCrit: true, LvlWarn: true, LvlInfo: false, LvlTrace: false} {
+		if child.Enabled(lvl) != expected {
+			t.Fatalf("wrong Enabled(%v), expected %v", lvl, expected)
+		}
+	}
+
+	calls := 0
+	lazy := Lazy{func() int { calls++; return calls }}
+	child.Debug("skipped", "lazy", lazy)
+	child.Warn("written", "lazy", lazy)
+	if r.Msg != "written" || calls != 1 {
+		t.Fatalf("expected only the warning to be evaluated, got msg %q and %d calls", r.Msg, calls)
+	}
+
+	l.SetHandler(FuncHandler(h.Log))
+	if !child.Enabled(LvlTrace) {
+		t.Fatalf("expected handlers without LvlChecker to be enabled")
+	}
+
+	filtered := LvlFilterHandler(LvlWarn, h)
+	if LvlEnabled(PassFuncHandler(filtered.Log, filtered), LvlInfo) {
+		t.Fatalf("expected PassFuncHandler to pass Enabled to the children")
+	}
+	if !LvlEnabled(ParentFuncHandler(filtered.Log, filtered), LvlInfo) {
+		t.Fatalf("expected ParentFuncHandler to be enabled for all levels")
+	}
+}
@@ -8,6 +8,10 @@ import (
 // ReName is a regular which tests valid app name for logger configuration
 var ReName = regexp.MustCompile(`^[[:alnum:]\-_.]{2,200}`)
 
+// ReLoggerName is a regular expression which tests valid logger names: dot
+// separated, non empty segments of letters, digits, '-' and '_'.
+var ReLoggerName = regexp.MustCompile(`^[[:alnum:]\-_]+(\.[[:alnum:]\-_]+)*$`)
+
 // CheckAppName validates the application name against `ReName`
 // `what` is the an optional argument to specify name category / family.
 func CheckAppName(name, what string) error {
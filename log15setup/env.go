@@ -0,0 +1,115 @@
+package log15setup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FromEnv creates the pipeline configuration from environment variables.
+// With prefix "LOG15" the following variables are read:
+//
+//     LOG15_LEVEL     root level, default: info
+//     LOG15_FORMAT    terminal, logfmt or json, default: terminal
+//     LOG15_COLOR     colored terminal output (true / false), default: false
+//     LOG15_TIME_FMT  terminal time format, one of timeFMT keys, default: sec
+//     LOG15_NAME      application name printed by the terminal format
+//     LOG15_CALLER    add caller file:line to records (true / false), default: false
+//     LOG15_LEVELS    per logger levels, eg: db=debug,http=warn
+//     LOG15_OUTPUTS   comma separated outputs, default: stderr. Supported outputs:
+//                     stdout, stderr, file:PATH, rotating_file:PATH, net:NETWORK:ADDRESS,
+//                     syslog, syslog:NETWORK:ADDRESS
+//
+// The returned config is validated with PipelineConfig.Check.
+func FromEnv(prefix string) (PipelineConfig, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	get := func(name, def string) string {
+		if v, ok := os.LookupEnv(prefix + name); ok {
+			return strings.TrimSpace(v)
+		}
+		return def
+	}
+	var errs []string
+	parseBool := func(name string) bool {
+		v := get(name, "false")
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s%s: Wrong value %q, should be true or false", prefix, name, v))
+		}
+		return b
+	}
+
+	c := PipelineConfig{
+		Name:    get("NAME", ""),
+		Caller:  parseBool("CALLER"),
+		Outputs: map[string]OutputConfig{},
+		Root:    RouteConfig{Level: get("LEVEL", "info")},
+		Loggers: map[string]RouteConfig{},
+	}
+	format := get("FORMAT", "terminal")
+	color := parseBool("COLOR")
+	timeFmt := get("TIME_FMT", "sec")
+
+	for i, spec := range strings.Split(get("OUTPUTS", "stderr"), ",") {
+		name := fmt.Sprintf("%sOUTPUTS[%d]", prefix, i)
+		o, err := parseOutput(strings.TrimSpace(spec))
+		if err != nil {
+			errs = append(errs, name+": "+err.Error())
+			continue
+		}
+		o.Format, o.Color, o.TimeFmt = format, color, timeFmt
+		c.Outputs[name] = o
+		c.Root.Outputs = append(c.Root.Outputs, name)
+	}
+	if levels := get("LEVELS", ""); levels != "" {
+		for _, kv := range strings.Split(levels, ",") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				errs = append(errs, fmt.Sprintf("%sLEVELS: Wrong entry %q, should be name=level", prefix, kv))
+				continue
+			}
+			c.Loggers[strings.TrimSpace(parts[0])] = RouteConfig{Level: strings.TrimSpace(parts[1])}
+		}
+	}
+	if len(errs) > 0 {
+		return c, errors.New("invalid log configuration:\n  " + strings.Join(errs, "\n  "))
+	}
+	return c, c.Check()
+}
+
+// parseOutput parses the output specification used by FromEnv.
+func parseOutput(spec string) (OutputConfig, error) {
+	parts := strings.SplitN(spec, ":", 3)
+	o := OutputConfig{Type: parts[0]}
+	switch {
+	case (o.Type == "stdout" || o.Type == "stderr") && len(parts) == 1:
+	case (o.Type == "file" || o.Type == "rotating_file") && len(parts) > 1:
+		o.Path = strings.SplitN(spec, ":", 2)[1]
+	case o.Type == "syslog" && len(parts) == 1:
+	case (o.Type == "net" || o.Type == "syslog") && len(parts) == 3:
+		o.Network, o.Address = parts[1], parts[2]
+	default:
+		return o, fmt.Errorf("Wrong output %q, should be one of: stdout, stderr, file:PATH, "+
+			"rotating_file:PATH, net:NETWORK:ADDRESS, syslog, syslog:NETWORK:ADDRESS", spec)
+	}
+	return o, nil
+}
+
+// ConfigureFromEnv builds the pipeline from environment variables (see FromEnv)
+// and applies it.
+func ConfigureFromEnv(prefix string) (*Pipeline, error) {
+	c, err := FromEnv(prefix)
+	if err != nil {
+		return nil, err
+	}
+	p, err := c.Build()
+	if err != nil {
+		return nil, err
+	}
+	p.Apply()
+	return p, nil
+}
@@ -0,0 +1,74 @@
+package log15setup
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func setEnv(t *testing.T, env map[string]string) func() {
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	defer setEnv(t, map[string]string{
+		"ENVTEST_LEVEL":   "warn",
+		"ENVTEST_FORMAT":  "json",
+		"ENVTEST_LEVELS":  "db=debug, http=error",
+		"ENVTEST_OUTPUTS": "stdout,file:/tmp/app.log,net:tcp:localhost:9000",
+	})()
+
+	c, err := FromEnv("ENVTEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Root.Level != "warn" || len(c.Root.Outputs) != 3 {
+		t.Fatalf("wrong root config: %+v", c.Root)
+	}
+	if o := c.Outputs["ENVTEST_OUTPUTS[1]"]; o.Type != "file" || o.Path != "/tmp/app.log" || o.Format != "json" {
+		t.Fatalf("wrong file output: %+v", o)
+	}
+	if o := c.Outputs["ENVTEST_OUTPUTS[2]"]; o.Network != "tcp" || o.Address != "localhost:9000" {
+		t.Fatalf("wrong net output: %+v", o)
+	}
+	if c.Loggers["db"].Level != "debug" || c.Loggers["http"].Level != "error" {
+		t.Fatalf("wrong logger levels: %+v", c.Loggers)
+	}
+}
+
+func TestFromEnvErrors(t *testing.T) {
+	defer setEnv(t, map[string]string{
+		"ENVERR_COLOR":    "maybe",
+		"ENVERR_LEVELS":   "db",
+		"ENVERR_OUTPUTS":  "kafka",
+		"ENVERR_TIME_FMT": "nanos",
+	})()
+
+	_, err := FromEnv("ENVERR_")
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, s := range []string{"ENVERR_COLOR: Wrong value \"maybe\"", "ENVERR_LEVELS: Wrong entry \"db\"",
+		"ENVERR_OUTPUTS[0]: Wrong output \"kafka\""} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("expected %q in the error:\n%v", s, err)
+		}
+	}
+
+	os.Setenv("ENVERR_COLOR", "true")
+	os.Setenv("ENVERR_LEVELS", "db=loud")
+	os.Setenv("ENVERR_OUTPUTS", "stderr")
+	_, err = FromEnv("ENVERR")
+	if err == nil || !strings.Contains(err.Error(), "timeFmt") || !strings.Contains(err.Error(), "Unknown level: loud") {
+		t.Fatalf("expected Check errors, got: %v", err)
+	}
+}
@@ -63,10 +63,12 @@ func Configure(name string, c Config, rc rollbar.Config) (log15.Logger, error) {
 		rollbarLogger.SetHandler(stderrHandler)
 		go rollbar.LogInternalErrors(rollbarLogger)
 	}
-	h = log15.CallerFileHandler(h, true)
-	h = log15.LvlFilterHandler(c.lvl, h)
+	h = log15.PassFuncHandler(log15.CallerFileHandler(h, true), h)
 	// l := log15.Get(name)
 	root.SetHandler(h)
+	// the root level is a registry level, not a filter handler, so log15.SetLevel
+	// can enable more verbose levels for chosen loggers
+	log15.SetLevel("", c.lvl)
 	if rc.Token == "" {
 		root.Info("Rollbar token not set. Disabling rollbar integration.")
 	}
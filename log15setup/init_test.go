@@ -0,0 +1,44 @@
+package log15setup
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/robert-zaremba/log15"
+	"github.com/robert-zaremba/log15/rollbar"
+)
+
+func TestConfigureLevels(t *testing.T) {
+	f, err := ioutil.TempFile("", "log15setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	old := root.GetHandler()
+	defer root.SetHandler(old)
+	defer log15.ClearLevel("")
+	stderr := os.Stderr
+	os.Stderr = f
+	_, err = Configure("app", Config{TimeFmt: "off", Level: "info"}, rollbar.Config{})
+	os.Stderr = stderr
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a child can be more verbose than the configured root level
+	log15.SetLevel("cfgtest.db", log15.LvlDebug)
+	defer log15.ClearLevel("cfgtest.db")
+	log15.Get("cfgtest.db.conn").Debug("db debug")
+	log15.Get("cfgtest").Debug("hidden")
+	log15.Get("cfgtest").Info("app info")
+
+	b, _ := ioutil.ReadFile(f.Name())
+	if !strings.Contains(string(b), "db debug") || !strings.Contains(string(b), "app info") ||
+		strings.Contains(string(b), "hidden") {
+		t.Fatalf("wrong log content: %s", b)
+	}
+}
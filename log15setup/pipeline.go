@@ -0,0 +1,423 @@
+package log15setup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/robert-zaremba/log15"
+	"gopkg.in/yaml.v2"
+)
+
+// PipelineConfig describes the whole handler tree: named outputs and the routing
+// of loggers to them. Example (YAML):
+//
+//     caller: true
+//     outputs:
+//       console: {type: stderr, format: terminal, color: true, timeFmt: sec}
+//       app:
+//         type: rotating_file
+//         path: /var/log/app.log
+//         format: json
+//         maxSize: 104857600
+//         maxBackups: 5
+//         level: info
+//     root: {level: info, outputs: [console, app]}
+//     loggers:
+//       db: {level: debug}
+//       http: {level: warn, outputs: [app]}
+//
+// Loggers inherit the level and the outputs from their nearest configured
+// ancestor (see log15.Get). A logger with `outputs` doesn't write to the outputs
+// of its ancestors.
+type PipelineConfig struct {
+	Name    string                  `yaml:"name" json:"name"` // used by the terminal format
+	Caller  bool                    `yaml:"caller" json:"caller"`
+	Outputs map[string]OutputConfig `yaml:"outputs" json:"outputs"`
+	Root    RouteConfig             `yaml:"root" json:"root"`
+	Loggers map[string]RouteConfig  `yaml:"loggers" json:"loggers"`
+}
+
+// RouteConfig configures the level and outputs of a logger.
+type RouteConfig struct {
+	Level   string   `yaml:"level" json:"level"`
+	Outputs []string `yaml:"outputs" json:"outputs"`
+}
+
+// OutputConfig describes a single output.
+type OutputConfig struct {
+	// Type is one of: stdout, stderr, file, rotating_file, syslog, net
+	Type string `yaml:"type" json:"type"`
+	// Format is one of: terminal, logfmt, json
+	Format  string `yaml:"format" json:"format"`
+	Color   bool   `yaml:"color" json:"color"`     // terminal format only
+	TimeFmt string `yaml:"timeFmt" json:"timeFmt"` // terminal format only, one of timeFMT keys
+	// Level filters out records less severe than it.
+	Level string `yaml:"level" json:"level"`
+
+	Path string `yaml:"path" json:"path"` // file and rotating_file
+	// rotating_file only
+	MaxSize    int64  `yaml:"maxSize" json:"maxSize"`
+	Period     string `yaml:"period" json:"period"` // hourly or daily
+	MaxBackups int    `yaml:"maxBackups" json:"maxBackups"`
+	Compress   bool   `yaml:"compress" json:"compress"`
+	// ReopenOnSIGHUP reopens the file when the process receives SIGHUP, for
+	// external rotation tools. SIGHUP doesn't terminate the process then.
+	ReopenOnSIGHUP bool `yaml:"reopenOnSIGHUP" json:"reopenOnSIGHUP"`
+
+	// net and syslog (optional, local syslog daemon is used if empty)
+	Network string `yaml:"network" json:"network"`
+	Address string `yaml:"address" json:"address"`
+	// syslog only
+	Tag      string `yaml:"tag" json:"tag"`
+	Facility string `yaml:"facility" json:"facility"`
+}
+
+var periods = map[string]log15.RotationPeriod{
+	"":       log15.RotateNever,
+	"hourly": log15.RotateHourly,
+	"daily":  log15.RotateDaily,
+}
+
+// ReadPipelineConfig reads the pipeline configuration from a YAML (.yaml, .yml)
+// or JSON (.json) file.
+func ReadPipelineConfig(path string) (PipelineConfig, error) {
+	var c PipelineConfig
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &c)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&c)
+	default:
+		return c, errors.New("Wrong config file extension " + path + ", should be .yaml, .yml or .json")
+	}
+	if err != nil {
+		return c, fmt.Errorf("can't parse %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Check validates the whole config and returns all problems found.
+func (c PipelineConfig) Check() error {
+	var errs []string
+	add := func(path, format string, args ...interface{}) {
+		errs = append(errs, path+": "+fmt.Sprintf(format, args...))
+	}
+	for _, name := range sortedKeys(c.Outputs) {
+		c.Outputs[name].check("outputs."+name, add)
+	}
+	c.Root.check("root", c.Outputs, add)
+	if len(c.Root.Outputs) == 0 {
+		add("root.outputs", "Wrong `outputs` value. Can't be empty")
+	}
+	names := make([]string, 0, len(c.Loggers))
+	for name := range c.Loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := "loggers." + name
+		if !ReLoggerName.MatchString(name) {
+			add(path, "Wrong logger name %q. Should match the following regexp: %s", name, ReLoggerName)
+		}
+		c.Loggers[name].check(path, c.Outputs, add)
+	}
+	if len(errs) > 0 {
+		return errors.New("invalid log configuration:\n  " + strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+func (r RouteConfig) check(path string, outputs map[string]OutputConfig,
+	add func(path, format string, args ...interface{})) {
+	if r.Level != "" {
+		if _, err := log15.LvlFromString(r.Level); err != nil {
+			add(path+".level", "%v", err)
+		}
+	}
+	for i, o := range r.Outputs {
+		if _, ok := outputs[o]; !ok {
+			add(fmt.Sprintf("%s.outputs[%d]", path, i), "Unknown output %q", o)
+		}
+	}
+}
+
+func (o OutputConfig) check(path string, add func(path, format string, args ...interface{})) {
+	switch o.Format {
+	case "terminal":
+		if _, ok := timeFMT[o.TimeFmt]; !ok {
+			add(path+".timeFmt", "Wrong `timeFmt` value %q, should be one of log15setup.timeFMT keys", o.TimeFmt)
+		}
+	case "logfmt", "json":
+	default:
+		add(path+".format", "Wrong `format` value %q, should be one of: terminal, logfmt, json", o.Format)
+	}
+	if o.Level != "" {
+		if _, err := log15.LvlFromString(o.Level); err != nil {
+			add(path+".level", "%v", err)
+		}
+	}
+	switch o.Type {
+	case "stdout", "stderr":
+	case "file", "rotating_file":
+		if o.Path == "" {
+			add(path+".path", "Wrong `path` value. Can't be empty for %s output", o.Type)
+		}
+		if o.Type == "file" {
+			break
+		}
+		if _, ok := periods[o.Period]; !ok {
+			add(path+".period", "Wrong `period` value %q, should be one of: hourly, daily", o.Period)
+		}
+		if o.MaxSize < 0 || o.MaxBackups < 0 {
+			add(path, "Wrong `maxSize` or `maxBackups` value. Can't be negative")
+		}
+	case "net":
+		if o.Network == "" || o.Address == "" {
+			add(path, "Wrong `network` or `address` value. Can't be empty for net output")
+		}
+	case "syslog":
+		if (o.Network == "") != (o.Address == "") {
+			add(path, "Wrong `network` and `address` values. Both must be set or empty")
+		}
+		if err := checkSyslogFacility(o.Facility); err != nil {
+			add(path+".facility", "%v", err)
+		}
+	default:
+		add(path+".type", "Wrong `type` value %q, should be one of: stdout, stderr, file, rotating_file, syslog, net", o.Type)
+	}
+}
+
+func sortedKeys(m map[string]OutputConfig) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Pipeline is the handler tree built from PipelineConfig.
+type Pipeline struct {
+	// Root is the handler of the root logger.
+	Root log15.Handler
+	// Loggers are the handlers of the loggers which have own outputs.
+	Loggers map[string]log15.Handler
+	// Levels are the configured logger levels. The root level has the empty key.
+	Levels map[string]log15.Lvl
+
+	outputs map[string]log15.Handler
+	mu      sync.RWMutex // held for reading while a record is being logged
+	// onShutdown is called when the root handler is closed, by log15.Shutdown.
+	onShutdown func(p *Pipeline)
+}
+
+// Build validates the config and opens all outputs. On error all outputs opened so far
+// are closed.
+func (c PipelineConfig) Build() (*Pipeline, error) {
+	if err := c.Check(); err != nil {
+		return nil, err
+	}
+	p := &Pipeline{
+		Loggers: map[string]log15.Handler{},
+		Levels:  map[string]log15.Lvl{},
+		outputs: map[string]log15.Handler{},
+	}
+	for _, name := range sortedKeys(c.Outputs) {
+		h, err := c.Outputs[name].build(c.Name)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("outputs.%s: %v", name, err)
+		}
+		p.outputs[name] = h
+	}
+	p.Root = p.route(c.Root.Outputs, c.Caller)
+	// log15.Shutdown closes only the root handler
+	p.Root.(*drainHandler).closeAll = true
+	if c.Root.Level != "" {
+		p.Levels[""], _ = log15.LvlFromString(c.Root.Level)
+	}
+	for name, r := range c.Loggers {
+		if len(r.Outputs) > 0 {
+			p.Loggers[name] = p.route(r.Outputs, c.Caller)
+		}
+		if r.Level != "" {
+			p.Levels[name], _ = log15.LvlFromString(r.Level)
+		}
+	}
+	return p, nil
+}
+
+func (p *Pipeline) route(outputs []string, caller bool) log15.Handler {
+	hs := make([]log15.Handler, len(outputs))
+	for i, o := range outputs {
+		hs[i] = p.outputs[o]
+	}
+	h := hs[0]
+	if len(hs) > 1 {
+		h = log15.MultiHandler(hs...)
+	}
+	if caller {
+		h = log15.PassFuncHandler(log15.CallerFileHandler(h, true), h)
+	}
+	return &drainHandler{h: h, p: p}
+}
+
+// drainHandler lets Pipeline.Close wait for the records being logged.
+// Closing the root handler closes the whole pipeline.
+type drainHandler struct {
+	h        log15.Handler
+	p        *Pipeline
+	closeAll bool
+}
+
+func (h *drainHandler) Log(r *log15.Record) error {
+	h.p.mu.RLock()
+	defer h.p.mu.RUnlock()
+	return h.h.Log(r)
+}
+
+func (h *drainHandler) Enabled(lvl log15.Lvl) bool {
+	return log15.LvlEnabled(h.h, lvl)
+}
+
+// Flush implements Flusher interface.
+func (h *drainHandler) Flush() error {
+	h.p.mu.RLock()
+	defer h.p.mu.RUnlock()
+	return log15.TryFlush(h.h)
+}
+
+// Close implements io.Closer interface.
+func (h *drainHandler) Close() error {
+	if h.closeAll {
+		err := h.p.Close()
+		if h.p.onShutdown != nil {
+			h.p.onShutdown(h.p)
+		}
+		return err
+	}
+	// wait for the records being logged
+	h.p.mu.Lock()
+	h.p.mu.Unlock()
+	return log15.TryClose(h.h)
+}
+
+func (o OutputConfig) build(appName string) (log15.Handler, error) {
+	var f log15.Format
+	switch o.Format {
+	case "terminal":
+		f = log15.TerminalFormat{WithColor: o.Color, TimeFmt: timeFMT[o.TimeFmt], Name: appName}
+	case "logfmt":
+		f = log15.LogfmtFormat()
+	default:
+		f = log15.JsonFormat()
+	}
+	var h log15.Handler
+	var err error
+	switch o.Type {
+	case "stdout":
+		h = log15.StreamHandler(os.Stdout, f)
+	case "stderr":
+		h = log15.StreamHandler(os.Stderr, f)
+	case "file":
+		h, err = log15.FileHandler(o.Path, f)
+	case "rotating_file":
+		h, err = log15.RotatingFileHandler(o.Path, f, log15.RotatingFileConfig{
+			MaxSize:        o.MaxSize,
+			Period:         periods[o.Period],
+			MaxBackups:     o.MaxBackups,
+			Compress:       o.Compress,
+			ReopenOnSIGHUP: o.ReopenOnSIGHUP,
+		})
+	case "net":
+		h, err = log15.NetHandler(o.Network, o.Address, f)
+	case "syslog":
+		h, err = syslogOutput(o, f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.Level != "" {
+		lvl, _ := log15.LvlFromString(o.Level)
+		h = log15.LvlFilterHandler(lvl, h)
+	}
+	return h, nil
+}
+
+// Apply installs the pipeline handlers and levels into the root logger and
+// the registered loggers.
+func (p *Pipeline) Apply() {
+	root.SetHandler(p.Root)
+	for name, h := range p.Loggers {
+		log15.Get(name).SetHandler(h)
+	}
+	for name, lvl := range p.Levels {
+		log15.SetLevel(name, lvl)
+	}
+}
+
+// Replace applies the pipeline in place of the old one: handlers and levels
+// of loggers which are not configured any more are reset, and the old outputs
+// are closed once the records being logged to them are written.
+func (p *Pipeline) Replace(old *Pipeline) error {
+	p.Apply()
+	if old == nil {
+		return nil
+	}
+	for name := range old.Loggers {
+		if _, ok := p.Loggers[name]; !ok {
+			log15.InheritHandler(name)
+		}
+	}
+	for name := range old.Levels {
+		if _, ok := p.Levels[name]; !ok {
+			log15.ClearLevel(name)
+		}
+	}
+	return old.Close()
+}
+
+// Close waits for the records being logged and closes all outputs of the pipeline.
+// The outputs are closed without holding the pipeline lock, as they may log
+// through the pipeline while closing.
+func (p *Pipeline) Close() error {
+	p.mu.Lock()
+	outputs := p.outputs
+	p.outputs = nil
+	p.mu.Unlock()
+	var err error
+	for _, h := range outputs {
+		if cerr := log15.TryClose(h); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ConfigurePipeline reads the pipeline config file, builds and applies it.
+func ConfigurePipeline(path string) (*Pipeline, error) {
+	c, err := ReadPipelineConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := c.Build()
+	if err != nil {
+		return nil, err
+	}
+	p.Apply()
+	return p, nil
+}
@@ -0,0 +1,176 @@
+package log15setup
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robert-zaremba/log15"
+)
+
+func TestPipelineCheck(t *testing.T) {
+	c := PipelineConfig{
+		Outputs: map[string]OutputConfig{
+			"a": {Type: "file", Format: "xml"},
+			"b": {Type: "pipe", Format: "json", Level: "loud"},
+		},
+		Root:    RouteConfig{Level: "info", Outputs: []string{"a", "c"}},
+		Loggers: map[string]RouteConfig{"db": {Level: "debug", Outputs: []string{"d"}}},
+	}
+	err := c.Check()
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, s := range []string{
+		"outputs.a.format: Wrong `format` value \"xml\"",
+		"outputs.a.path: Wrong `path` value",
+		"outputs.b.level: Unknown level: loud",
+		"outputs.b.type: Wrong `type` value \"pipe\"",
+		"root.outputs[1]: Unknown output \"c\"",
+		"loggers.db.outputs[0]: Unknown output \"d\"",
+	} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("expected %q in the error:\n%v", s, err)
+		}
+	}
+}
+
+func TestLoggerName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"a":          true,
+		"db":         true,
+		"db.pool":    true,
+		"http-2.v_1": true,
+		"":           false,
+		"db$%^":      false,
+		".db":        false,
+		"db.":        false,
+		"db..pool":   false,
+		"db.*":       false,
+		"db pool":    false,
+	} {
+		err := PipelineConfig{
+			Outputs: map[string]OutputConfig{"o": {Type: "stdout", Format: "json"}},
+			Root:    RouteConfig{Outputs: []string{"o"}},
+			Loggers: map[string]RouteConfig{name: {Level: "debug"}},
+		}.Check()
+		if (err == nil) != valid {
+			t.Fatalf("wrong validation of %q: %v", name, err)
+		}
+	}
+}
+
+func TestPipelineBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log15setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := filepath.Join(dir, "log.yaml")
+	appLog := filepath.Join(dir, "app.log")
+	dbLog := filepath.Join(dir, "db.log")
+	err = ioutil.WriteFile(cfg, []byte(`
+outputs:
+  app: {type: file, path: `+appLog+`, format: logfmt}
+  db: {type: rotating_file, path: `+dbLog+`, format: json, maxSize: 1000000, level: debug}
+root: {level: info, outputs: [app]}
+loggers:
+  pipetest.db: {level: debug, outputs: [db]}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := root.GetHandler()
+	defer root.SetHandler(old)
+	defer log15.ClearLevel("")
+	defer log15.ClearLevel("pipetest.db")
+	p, err := ConfigurePipeline(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log15.Get("pipetest").Debug("hidden")
+	log15.Get("pipetest").Info("to app")
+	log15.Get("pipetest.db.conn").Debug("to db")
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := ioutil.ReadFile(appLog)
+	if !strings.Contains(string(b), "to app") || strings.Contains(string(b), "hidden") ||
+		strings.Contains(string(b), "to db") {
+		t.Fatalf("wrong app log content: %s", b)
+	}
+	b, _ = ioutil.ReadFile(dbLog)
+	if !strings.Contains(string(b), `"msg":"to db"`) {
+		t.Fatalf("wrong db log content: %s", b)
+	}
+}
+
+func TestPipelineShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log15setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p, err := PipelineConfig{
+		Outputs: map[string]OutputConfig{
+			"app": {Type: "file", Path: filepath.Join(dir, "app.log"), Format: "logfmt"},
+			"db":  {Type: "rotating_file", Path: filepath.Join(dir, "db.log"), Format: "json", MaxSize: 1000000},
+		},
+		Root:    RouteConfig{Outputs: []string{"app"}},
+		Loggers: map[string]RouteConfig{"shutdowntest.db": {Outputs: []string{"db"}}},
+	}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := root.GetHandler()
+	defer root.SetHandler(old)
+	defer log15.InheritHandler("shutdowntest.db")
+	p.Apply()
+	if err = log15.TryFlush(root.GetHandler()); err != nil {
+		t.Fatal(err)
+	}
+	if err = log15.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the outputs of all loggers are closed
+	for name, h := range p.outputs {
+		if err := h.Log(&log15.Record{Msg: "after shutdown"}); err == nil {
+			t.Fatalf("expected the %s output to be closed", name)
+		}
+	}
+}
+
+// closeFunc is an output which runs a function on Close.
+type closeFunc func() error
+
+func (f closeFunc) Log(r *log15.Record) error { return nil }
+func (f closeFunc) Close() error              { return f() }
+
+func TestPipelineCloseLogging(t *testing.T) {
+	p := &Pipeline{Loggers: map[string]log15.Handler{}, Levels: map[string]log15.Lvl{}}
+	p.outputs = map[string]log15.Handler{"o": closeFunc(func() error {
+		// eg: a network output reporting an error while closing
+		return p.Root.Log(&log15.Record{Msg: "closing"})
+	})}
+	p.Root = p.route([]string{"o"}, false)
+	done := make(chan error, 1)
+	go func() { done <- p.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close deadlocked")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("unexpected error closing again: %v", err)
+	}
+}
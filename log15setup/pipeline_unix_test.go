@@ -0,0 +1,41 @@
+// +build !windows,!plan9
+
+package log15setup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/robert-zaremba/log15"
+)
+
+func TestPipelineReopenOnSIGHUP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log15setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	h, err := OutputConfig{Type: "rotating_file", Path: path, Format: "logfmt", ReopenOnSIGHUP: true}.build("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log15.TryClose(h)
+
+	// an external tool moves the file away
+	if err = os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		h.Log(&log15.Record{Msg: "reopened"})
+		if _, err = os.Stat(path); err == nil {
+			return
+		}
+	}
+	t.Fatalf("expected the file to be reopened on SIGHUP")
+}
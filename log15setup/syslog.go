@@ -0,0 +1,43 @@
+// +build !windows,!plan9
+
+package log15setup
+
+import (
+	"errors"
+	"log/syslog"
+
+	"github.com/robert-zaremba/log15"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"":       syslog.LOG_USER,
+	"kern":   syslog.LOG_KERN,
+	"user":   syslog.LOG_USER,
+	"mail":   syslog.LOG_MAIL,
+	"daemon": syslog.LOG_DAEMON,
+	"auth":   syslog.LOG_AUTH,
+	"syslog": syslog.LOG_SYSLOG,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+func checkSyslogFacility(name string) error {
+	if _, ok := syslogFacilities[name]; !ok {
+		return errors.New("Wrong `facility` value " + name + ", should be one of: kern, user, mail, daemon, auth, syslog, local0 .. local7")
+	}
+	return nil
+}
+
+func syslogOutput(o OutputConfig, f log15.Format) (log15.Handler, error) {
+	p := syslogFacilities[o.Facility] | syslog.LOG_INFO
+	if o.Network == "" {
+		return log15.SyslogHandler(p, o.Tag, f)
+	}
+	return log15.SyslogNetHandler(o.Network, o.Address, p, o.Tag, f)
+}
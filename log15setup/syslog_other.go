@@ -0,0 +1,19 @@
+// +build windows plan9
+
+package log15setup
+
+import (
+	"errors"
+
+	"github.com/robert-zaremba/log15"
+)
+
+var errNoSyslog = errors.New("syslog output is not supported on this platform")
+
+func checkSyslogFacility(name string) error {
+	return errNoSyslog
+}
+
+func syslogOutput(o OutputConfig, f log15.Format) (log15.Handler, error) {
+	return nil, errNoSyslog
+}
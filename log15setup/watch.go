@@ -0,0 +1,157 @@
+package log15setup
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/robert-zaremba/log15"
+)
+
+// Watcher reloads the pipeline configuration file when it changes or when
+// the process receives SIGHUP. The new handler tree is built aside and replaces
+// the current one only if it's valid, otherwise the error is logged and
+// the current configuration stays.
+//
+// log15.Shutdown stops the watcher, as it closes the current pipeline.
+type Watcher struct {
+	path     string
+	interval time.Duration
+
+	mu       sync.Mutex // guards the fields below and serializes reloads
+	p        *Pipeline
+	modTime  time.Time
+	size     int64
+	shutdown bool
+
+	sighup     chan os.Signal
+	stop       chan struct{}
+	done       chan struct{}
+	shutdownCh chan struct{} // closed by log15.Shutdown
+}
+
+// errShutdown is returned by Reload after log15.Shutdown.
+var errShutdown = errors.New("log15setup: logging was shut down")
+
+// WatchPipeline configures logging from the pipeline config file (see ConfigurePipeline)
+// and reloads it on SIGHUP and when the file modification time or size change.
+// The file is checked every interval; zero interval disables the checks.
+func WatchPipeline(path string, interval time.Duration) (*Watcher, error) {
+	w := &Watcher{
+		path:       path,
+		interval:   interval,
+		sighup:     make(chan os.Signal, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		shutdownCh: make(chan struct{}),
+	}
+	if err := w.Reload(); err != nil {
+		return nil, err
+	}
+	signal.Notify(w.sighup, syscall.SIGHUP)
+	go w.loop()
+	return w, nil
+}
+
+func (w *Watcher) loop() {
+	defer close(w.done)
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		t := time.NewTicker(w.interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		var err error
+		select {
+		case <-w.stop:
+			return
+		case <-w.shutdownCh:
+			signal.Stop(w.sighup)
+			return
+		case <-w.sighup:
+			err = w.Reload()
+		case <-tick:
+			if w.changed() {
+				err = w.Reload()
+			}
+		}
+		if err != nil {
+			root.Error("Can't reload log configuration", "path", w.path, err)
+		}
+	}
+}
+
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
+
+// Reload reads and builds the config file and replaces the current pipeline with it.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.shutdown {
+		return errShutdown
+	}
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	// remember the file state even if it's wrong, to not report it on every check
+	w.modTime, w.size = info.ModTime(), info.Size()
+	c, err := ReadPipelineConfig(w.path)
+	if err != nil {
+		return err
+	}
+	p, err := c.Build()
+	if err != nil {
+		return err
+	}
+	p.onShutdown = w.onShutdown
+	old := w.p
+	w.p = p
+	return p.Replace(old)
+}
+
+// onShutdown stops the watcher when log15.Shutdown closes the pipeline p.
+func (w *Watcher) onShutdown(p *Pipeline) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.shutdown {
+		return
+	}
+	w.shutdown = true
+	close(w.shutdownCh)
+	if w.p != p {
+		// a reload applied a new pipeline while shutting down
+		root.SetHandler(log15.DiscardHandler())
+		w.p.Close()
+	}
+}
+
+// Pipeline returns the current pipeline.
+func (w *Watcher) Pipeline() *Pipeline {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.p
+}
+
+// Close stops watching and closes the outputs of the current pipeline.
+// It isn't needed after log15.Shutdown.
+func (w *Watcher) Close() error {
+	signal.Stop(w.sighup)
+	close(w.stop)
+	<-w.done
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.p.Close()
+}
@@ -0,0 +1,110 @@
+package log15setup
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robert-zaremba/log15"
+)
+
+func TestWatchPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log15setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := filepath.Join(dir, "log.json")
+	log1 := filepath.Join(dir, "1.log")
+	log2 := filepath.Join(dir, "2.log")
+	write := func(path, lvl string) {
+		c := `{"outputs": {"out": {"type": "file", "format": "logfmt", "path": "` + path + `"}},
+			"root": {"outputs": ["out"]},
+			"loggers": {"watchtest": {"level": "` + lvl + `", "outputs": ["out"]}}}`
+		if err := ioutil.WriteFile(cfg, []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := root.GetHandler()
+	defer root.SetHandler(old)
+	defer log15.ClearLevel("watchtest")
+	defer log15.InheritHandler("watchtest")
+	write(log1, "info")
+	w, err := WatchPipeline(cfg, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	l := log15.Get("watchtest")
+	l.Info("first")
+	first := w.Pipeline()
+
+	// make sure the modification time changes on file systems with coarse timestamps
+	time.Sleep(20 * time.Millisecond)
+	write(log2, "debug")
+	deadline := time.Now().Add(2 * time.Second)
+	for w.Pipeline() == first && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if w.Pipeline() == first {
+		t.Fatalf("expected the configuration to be reloaded")
+	}
+	l.Debug("second")
+
+	if err = ioutil.WriteFile(cfg, []byte(`{"outputs": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Reload(); err == nil {
+		t.Fatalf("expected invalid configuration to be rejected")
+	}
+	l.Debug("third")
+
+	b, _ := ioutil.ReadFile(log1)
+	if !strings.Contains(string(b), "first") || strings.Contains(string(b), "second") {
+		t.Fatalf("wrong content of the first log: %s", b)
+	}
+	b, _ = ioutil.ReadFile(log2)
+	if !strings.Contains(string(b), "second") || !strings.Contains(string(b), "third") {
+		t.Fatalf("wrong content of the second log: %s", b)
+	}
+}
+
+func TestWatchPipelineShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log15setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := filepath.Join(dir, "log.json")
+	c := `{"outputs": {"out": {"type": "file", "format": "logfmt", "path": "` + filepath.Join(dir, "1.log") + `"}},
+		"root": {"outputs": ["out"]}}`
+	if err = ioutil.WriteFile(cfg, []byte(c), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	old := root.GetHandler()
+	defer root.SetHandler(old)
+	w, err := WatchPipeline(cfg, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = log15.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the watcher to stop")
+	}
+	if err = w.Reload(); err != errShutdown {
+		t.Fatalf("expected the reload to fail after shutdown, got %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
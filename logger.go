@@ -1,8 +1,10 @@
 package log15
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-stack/stack"
@@ -50,6 +52,8 @@ func (l Lvl) StringUP() string {
 func LvlFromString(lvlString string) (Lvl, error) {
 	lvlString = strings.ToLower(lvlString)
 	switch lvlString {
+	case "trace", "trce":
+		return LvlTrace, nil
 	case "debug", "dbug":
 		return LvlDebug, nil
 	case "info":
@@ -58,7 +62,7 @@ func LvlFromString(lvlString string) (Lvl, error) {
 		return LvlWarn, nil
 	case "error", "eror":
 		return LvlError, nil
-	case "crit":
+	case "crit", "criti":
 		return LvlCrit, nil
 	default:
 		return LvlDebug, fmt.Errorf("Unknown level: %v", lvlString)
@@ -73,6 +77,10 @@ type Record struct {
 	Ctx      []interface{}
 	Call     stack.Call
 	KeyNames RecordKeyNames
+	// Context is the context passed to the XxxCtx logging methods, nil otherwise.
+	Context context.Context
+	// Name is the registry name of the logger (see Get), empty for the root logger.
+	Name string
 }
 
 // RecordKeyNames are the predefined names of the log props used by the Logger interface.
@@ -93,6 +101,10 @@ type Logger interface {
 	// SetHandler updates the logger to write records to the specified handler.
 	SetHandler(h Handler)
 
+	// Enabled reports whether records of the given level may be written by the handler.
+	// Use it to avoid computing expensive context values for discarded records.
+	Enabled(lvl Lvl) bool
+
 	// Log a message at the given level with context key/value pairs
 	Trace(msg string, ctx ...interface{})
 	Debug(msg string, ctx ...interface{})
@@ -103,34 +115,62 @@ type Logger interface {
 
 	// Fatal is a Crit log followed by panic
 	Fatal(msg string, ctx ...interface{})
+
+	// Log a message with the context.Context attached to the record, so
+	// handlers can use it (see ContextHandler).
+	TraceCtx(c context.Context, msg string, ctx ...interface{})
+	DebugCtx(c context.Context, msg string, ctx ...interface{})
+	InfoCtx(c context.Context, msg string, ctx ...interface{})
+	WarnCtx(c context.Context, msg string, ctx ...interface{})
+	ErrorCtx(c context.Context, msg string, ctx ...interface{})
+	CritCtx(c context.Context, msg string, ctx ...interface{})
 }
 
 type logger struct {
-	ctx []interface{}
-	h   *swapHandler
+	ctx  atomic.Value // []interface{}, replaced by Set
+	h    *swapHandler
+	name string
+	lvl  *lvlGate // shared by all loggers with the same registry name
+}
+
+func (l *logger) write(c context.Context, msg string, lvl Lvl, ctx []interface{}) {
+	// fast path: don't build records which would be discarded
+	if !l.lvl.enabled(lvl) || !l.h.Enabled(lvl) {
+		return
+	}
+	l.writeCall(c, msg, lvl, ctx, stack.Caller(2))
 }
 
-func (l *logger) write(msg string, lvl Lvl, ctx []interface{}) {
+// writeCall logs the record with the given call site. The level must be checked
+// with Enabled before.
+func (l *logger) writeCall(c context.Context, msg string, lvl Lvl, ctx []interface{}, call stack.Call) {
 	l.h.Log(&Record{
 		Time: time.Now(),
 		Lvl:  lvl,
 		Msg:  msg,
-		Ctx:  newContext(l.ctx, ctx),
-		Call: stack.Caller(2),
+		Ctx:  newContext(l.context(), ctx),
+		Call: call,
 		KeyNames: RecordKeyNames{
 			Time: timeKey,
 			Msg:  msgKey,
 			Lvl:  lvlKey,
 		},
+		Context: c,
+		Name:    l.name,
 	})
 }
 
 func (l *logger) New(ctx ...interface{}) Logger {
-	child := &logger{newContext(l.ctx, ctx), new(swapHandler)}
+	child := &logger{h: new(swapHandler), name: l.name, lvl: l.lvl}
+	child.ctx.Store(newContext(l.context(), ctx))
 	child.SetHandler(l.h)
 	return child
 }
 
+func (l *logger) context() []interface{} {
+	return l.ctx.Load().([]interface{})
+}
+
 func newContext(prefix []interface{}, suffix []interface{}) []interface{} {
 	normalizedSuffix := normalize(suffix)
 	newCtx := make([]interface{}, len(prefix)+len(normalizedSuffix))
@@ -140,34 +180,58 @@ func newContext(prefix []interface{}, suffix []interface{}) []interface{} {
 }
 
 func (l *logger) Trace(msg string, ctx ...interface{}) {
-	l.write(msg, LvlTrace, ctx)
+	l.write(nil, msg, LvlTrace, ctx)
 }
 
 func (l *logger) Debug(msg string, ctx ...interface{}) {
-	l.write(msg, LvlDebug, ctx)
+	l.write(nil, msg, LvlDebug, ctx)
 }
 
 func (l *logger) Info(msg string, ctx ...interface{}) {
-	l.write(msg, LvlInfo, ctx)
+	l.write(nil, msg, LvlInfo, ctx)
 }
 
 func (l *logger) Warn(msg string, ctx ...interface{}) {
-	l.write(msg, LvlWarn, ctx)
+	l.write(nil, msg, LvlWarn, ctx)
 }
 
 func (l *logger) Error(msg string, ctx ...interface{}) {
-	l.write(msg, LvlError, ctx)
+	l.write(nil, msg, LvlError, ctx)
 }
 
 func (l *logger) Crit(msg string, ctx ...interface{}) {
-	l.write(msg, LvlCrit, ctx)
+	l.write(nil, msg, LvlCrit, ctx)
 }
 
 func (l *logger) Fatal(msg string, ctx ...interface{}) {
-	l.write(msg, LvlCrit, ctx)
+	l.write(nil, msg, LvlCrit, ctx)
 	panic(FatalMessage(msg))
 }
 
+func (l *logger) TraceCtx(c context.Context, msg string, ctx ...interface{}) {
+	l.write(c, msg, LvlTrace, ctx)
+}
+
+func (l *logger) DebugCtx(c context.Context, msg string, ctx ...interface{}) {
+	l.write(c, msg, LvlDebug, ctx)
+}
+
+func (l *logger) InfoCtx(c context.Context, msg string, ctx ...interface{}) {
+	l.write(c, msg, LvlInfo, ctx)
+}
+
+func (l *logger) WarnCtx(c context.Context, msg string, ctx ...interface{}) {
+	l.write(c, msg, LvlWarn, ctx)
+}
+
+func (l *logger) ErrorCtx(c context.Context, msg string, ctx ...interface{}) {
+	l.write(c, msg, LvlError, ctx)
+}
+
+func (l *logger) CritCtx(c context.Context, msg string, ctx ...interface{}) {
+	l.write(c, msg, LvlCrit, ctx)
+}
+
 // FatalMessage is a wrapper for the message in fatal logging
 type FatalMessage string
 
@@ -183,6 +247,10 @@ func (l *logger) SetHandler(h Handler) {
 	l.h.Swap(h)
 }
 
+func (l *logger) Enabled(lvl Lvl) bool {
+	return l.lvl.enabled(lvl) && l.h.Enabled(lvl)
+}
+
 func normalize(ctx []interface{}) []interface{} {
 	// if the caller passed a Ctx object, then expand it
 	if len(ctx) == 1 {
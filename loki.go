@@ -0,0 +1,260 @@
+package log15
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LokiEncoding is the encoding of the Loki push requests.
+type LokiEncoding int
+
+// List of supported Loki push encodings
+const (
+	// LokiProtobuf sends snappy compressed protobuf requests.
+	LokiProtobuf LokiEncoding = iota
+	// LokiJSON sends JSON requests.
+	LokiJSON
+)
+
+// LokiConfig configures LokiHandler.
+type LokiConfig struct {
+	// URL of the push API, eg: http://localhost:3100/loki/api/v1/push
+	URL string
+	// Encoding of the push requests.
+	Encoding LokiEncoding
+	// Labels are added to every stream, eg: {"job": "api"}.
+	Labels map[string]string
+	// LabelKeys lists the context keys which become stream labels. They should
+	// have few distinct values, use the log line for the rest of the context.
+	LabelKeys []string
+	// Format of the log lines, usually LogfmtFormat() or JsonFormat().
+	// Trailing new lines are removed. Default: LogfmtFormat().
+	Format Format
+	// TenantID is sent in the X-Scope-OrgID header, if set.
+	TenantID string
+	// Header is added to the push requests, eg: for authorization.
+	Header http.Header
+	// Client sends the push requests. Default: a client with a 10s timeout.
+	Client *http.Client
+	// Batch configures batching and retries. The default BatchSize is 1000.
+	Batch BatchConfig
+}
+
+// LokiHandler returns a handler which pushes records to Grafana Loki.
+// Records are grouped into streams by their labels: the c.Labels, the values
+// of c.LabelKeys context keys, "level" and "logger" (the logger name, if it's set).
+// The context keys used as labels are removed from the record before it's
+// formatted to the log line.
+//
+// Records are sent asynchronously in batches. Requests failing with a network
+// error, 429 or 5xx status are retried with exponential backoff. Flush waits
+// for the logged records to be sent and Close sends the buffered records.
+func LokiHandler(c LokiConfig) (*Loki, error) {
+	if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+		return nil, errors.New("Wrong `URL` value " + c.URL + ", should be a http(s) URL of the push API")
+	}
+	if c.Format == nil {
+		c.Format = LogfmtFormat()
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if c.Batch.BatchSize <= 0 {
+		c.Batch.BatchSize = 1000
+	}
+	l := &Loki{c: c, labelKeys: make(map[string]bool, len(c.LabelKeys))}
+	for _, k := range c.LabelKeys {
+		l.labelKeys[k] = true
+	}
+	l.lazy = LazyHandler(FuncHandler(l.enqueue))
+	l.batcher = newBatcher(c.Batch.withDefaults(), nil, l.send)
+	return l, nil
+}
+
+// Loki is the Handler returned by LokiHandler.
+type Loki struct {
+	*batcher
+	c         LokiConfig
+	labelKeys map[string]bool
+	lazy      Handler
+}
+
+type lokiEntry struct {
+	labels map[string]string
+	stream string // labels in the Prometheus format, identifying the stream
+	time   time.Time
+	line   string
+}
+
+// Log implements Handler interface.
+func (l *Loki) Log(r *Record) error {
+	return l.lazy.Log(r)
+}
+
+func (l *Loki) enqueue(r *Record) error {
+	e := lokiEntry{labels: make(map[string]string, len(l.c.Labels)+2), time: r.Time}
+	if e.time.IsZero() {
+		e.time = time.Now()
+	}
+	for k, v := range l.c.Labels {
+		e.labels[k] = v
+	}
+	e.labels["level"] = strings.TrimSpace(r.Lvl.String())
+	if r.Name != "" {
+		e.labels["logger"] = r.Name
+	}
+	line := *r
+	if len(l.labelKeys) > 0 {
+		line.Ctx = make([]interface{}, 0, len(r.Ctx))
+		for i := 0; i < len(r.Ctx); i++ {
+			k, ok := r.Ctx[i].(string)
+			if !ok || i+1 >= len(r.Ctx) {
+				line.Ctx = append(line.Ctx, r.Ctx[i])
+				continue
+			}
+			if l.labelKeys[k] {
+				e.labels[lokiLabelName(k)] = fmt.Sprint(formatJSONValue(r.Ctx[i+1]))
+			} else {
+				line.Ctx = append(line.Ctx, k, r.Ctx[i+1])
+			}
+			i++
+		}
+	}
+	e.line = strings.TrimRight(string(l.c.Format.Format(&line)), "\n")
+	e.stream = lokiStream(e.labels)
+	return l.batcher.add(e)
+}
+
+// lokiLabelName replaces the characters not allowed in label names with '_'.
+func lokiLabelName(key string) string {
+	b := []byte(key)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= '0' && c <= '9' && i > 0) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// lokiStream formats the labels like Prometheus does: {a="1", b="2"}.
+func lokiStream(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf strings.Builder
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(strconv.Quote(labels[k]))
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// lokiStreams groups the entries by stream, in the order of their first entries.
+func lokiStreams(batch []interface{}) [][]lokiEntry {
+	var streams [][]lokiEntry
+	idx := make(map[string]int)
+	for _, item := range batch {
+		e := item.(lokiEntry)
+		i, ok := idx[e.stream]
+		if !ok {
+			i = len(streams)
+			idx[e.stream] = i
+			streams = append(streams, nil)
+		}
+		streams[i] = append(streams[i], e)
+	}
+	return streams
+}
+
+// encodeJSON encodes the push request in JSON:
+//
+//     {"streams": [{"stream": {"level": "info"}, "values": [["<unix ns>", "<line>"], ...]}, ...]}
+func (l *Loki) encodeJSON(streams [][]lokiEntry) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	req := struct {
+		Streams []stream `json:"streams"`
+	}{make([]stream, len(streams))}
+	for i, entries := range streams {
+		s := stream{Stream: entries[0].labels, Values: make([][2]string, len(entries))}
+		for j, e := range entries {
+			s.Values[j] = [2]string{strconv.FormatInt(e.time.UnixNano(), 10), e.line}
+		}
+		req.Streams[i] = s
+	}
+	return json.Marshal(req)
+}
+
+// encodeProtobuf encodes the push request in snappy compressed protobuf:
+//
+//     message PushRequest { repeated Stream streams = 1; }
+//     message Stream { string labels = 1; repeated Entry entries = 2; }
+//     message Entry { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+func (l *Loki) encodeProtobuf(streams [][]lokiEntry) []byte {
+	w := &protoWriter{}
+	for _, entries := range streams {
+		w.putMessage(1, func(w *protoWriter) {
+			w.putString(1, entries[0].stream)
+			for _, e := range entries {
+				w.putMessage(2, func(w *protoWriter) {
+					w.putMessage(1, func(w *protoWriter) {
+						w.putUint(1, uint64(e.time.Unix()))
+						w.putUint(2, uint64(e.time.Nanosecond()))
+					})
+					w.putString(2, e.line)
+				})
+			}
+		})
+	}
+	return snappyEncode(w.buf)
+}
+
+func (l *Loki) send(batch []interface{}) ([]interface{}, int, error) {
+	streams := lokiStreams(batch)
+	var body []byte
+	var err error
+	contentType := "application/x-protobuf"
+	if l.c.Encoding == LokiJSON {
+		contentType = "application/json"
+		if body, err = l.encodeJSON(streams); err != nil {
+			return nil, len(batch), err
+		}
+	} else {
+		body = l.encodeProtobuf(streams)
+	}
+	header := http.Header{}
+	if l.c.TenantID != "" {
+		header.Set("X-Scope-OrgID", l.c.TenantID)
+	}
+	if _, err := httpPost(l.c.Client, l.c.URL, contentType, body, l.c.Header, header); err != nil {
+		if isRetryable(err) {
+			return batch, 0, err
+		}
+		return nil, len(batch), err
+	}
+	return nil, 0, nil
+}
+
+func (m muster) LokiHandler(c LokiConfig) *Loki {
+	h, err := LokiHandler(c)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
@@ -0,0 +1,371 @@
+package log15
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// snappyDecode decodes the Snappy block format.
+func snappyDecode(src []byte) ([]byte, error) {
+	n, k := binary.Uvarint(src)
+	if k <= 0 {
+		return nil, errors.New("bad length")
+	}
+	src = src[k:]
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		tag := src[0]
+		if tag&3 == 0 {
+			l := int(tag >> 2)
+			src = src[1:]
+			if l >= 60 {
+				m := l - 59
+				l = 0
+				for i := m - 1; i >= 0; i-- {
+					l = l<<8 | int(src[i])
+				}
+				src = src[m:]
+			}
+			l++
+			dst = append(dst, src[:l]...)
+			src = src[l:]
+			continue
+		}
+		// copies with 1, 2 and 4 byte offsets
+		var l, off int
+		switch tag & 3 {
+		case 1:
+			l = int(tag>>2&7) + 4
+			off = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+		case 2:
+			l = int(tag>>2) + 1
+			off = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3:
+			l = int(tag>>2) + 1
+			off = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if off == 0 || off > len(dst) {
+			return nil, errors.New("bad offset")
+		}
+		for i := 0; i < l; i++ {
+			dst = append(dst, dst[len(dst)-off])
+		}
+	}
+	if uint64(len(dst)) != n {
+		return nil, errors.New("bad decoded length")
+	}
+	return dst, nil
+}
+
+func TestSnappyEncode(t *testing.T) {
+	t.Parallel()
+
+	random := make([]byte, 5000)
+	rand.Read(random)
+	for _, src := range [][]byte{
+		nil, []byte("a"), []byte("abcabcabcabcabcabcabc"), bytes.Repeat([]byte("log15 "), 1000), random,
+		append(random, random...),
+	} {
+		enc := snappyEncode(src)
+		dec, err := snappyDecode(enc)
+		if err != nil || !bytes.Equal(dec, src) {
+			t.Fatalf("wrong round trip of %d bytes: %v", len(src), err)
+		}
+	}
+	if n := len(snappyEncode(bytes.Repeat([]byte("log15 "), 1000))); n > 300 {
+		t.Fatalf("repeated data not compressed: %d bytes", n)
+	}
+}
+
+// lokiPushVector is a push request encoded by the reference implementations:
+// the Loki logproto messages marshalled with google.golang.org/protobuf and
+// compressed with github.com/golang/snappy Encode.
+var lokiPushVector = struct{ proto, snappy []byte }{
+	proto: []byte{
+		0x0a, 0x65, 0x0a, 0x1b, 0x7b, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x3d, 0x22, 0x69, 0x6e, 0x66, 0x6f,
+		0x22, 0x2c, 0x20, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x3d, 0x22, 0x64, 0x62, 0x22, 0x7d, 0x12,
+		0x25, 0x0a, 0x0b, 0x08, 0x80, 0xde, 0xa0, 0xcb, 0x05, 0x10, 0x95, 0x9a, 0xef, 0x3a, 0x12, 0x16,
+		0x6d, 0x73, 0x67, 0x3d, 0x22, 0x71, 0x75, 0x65, 0x72, 0x79, 0x20, 0x64, 0x6f, 0x6e, 0x65, 0x22,
+		0x20, 0x6d, 0x73, 0x3d, 0x31, 0x32, 0x12, 0x1f, 0x0a, 0x06, 0x08, 0x81, 0xde, 0xa0, 0xcb, 0x05,
+		0x12, 0x15, 0x6d, 0x73, 0x67, 0x3d, 0x22, 0x71, 0x75, 0x65, 0x72, 0x79, 0x20, 0x64, 0x6f, 0x6e,
+		0x65, 0x22, 0x20, 0x6d, 0x73, 0x3d, 0x37, 0x0a, 0x34, 0x0a, 0x0e, 0x7b, 0x6c, 0x65, 0x76, 0x65,
+		0x6c, 0x3d, 0x22, 0x77, 0x61, 0x72, 0x6e, 0x22, 0x7d, 0x12, 0x22, 0x0a, 0x06, 0x08, 0x80, 0xde,
+		0xa0, 0xcb, 0x05, 0x12, 0x18, 0x6d, 0x73, 0x67, 0x3d, 0x22, 0x73, 0x6c, 0x6f, 0x77, 0x20, 0x71,
+		0x75, 0x65, 0x72, 0x79, 0x22, 0x20, 0x6d, 0x73, 0x3d, 0x31, 0x35, 0x30, 0x30,
+	},
+	snappy: []byte{
+		0x9d, 0x01, 0xf0, 0x52, 0x0a, 0x65, 0x0a, 0x1b, 0x7b, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x3d, 0x22,
+		0x69, 0x6e, 0x66, 0x6f, 0x22, 0x2c, 0x20, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x3d, 0x22, 0x64,
+		0x62, 0x22, 0x7d, 0x12, 0x25, 0x0a, 0x0b, 0x08, 0x80, 0xde, 0xa0, 0xcb, 0x05, 0x10, 0x95, 0x9a,
+		0xef, 0x3a, 0x12, 0x16, 0x6d, 0x73, 0x67, 0x3d, 0x22, 0x71, 0x75, 0x65, 0x72, 0x79, 0x20, 0x64,
+		0x6f, 0x6e, 0x65, 0x22, 0x20, 0x6d, 0x73, 0x3d, 0x31, 0x32, 0x12, 0x1f, 0x0a, 0x06, 0x08, 0x81,
+		0xde, 0xa0, 0xcb, 0x05, 0x12, 0x15, 0x6d, 0x4a, 0x22, 0x00, 0x10, 0x37, 0x0a, 0x34, 0x0a, 0x0e,
+		0x11, 0x67, 0x24, 0x77, 0x61, 0x72, 0x6e, 0x22, 0x7d, 0x12, 0x22, 0x0a, 0x06, 0x09, 0x5a, 0x08,
+		0x12, 0x18, 0x6d, 0x01, 0x33, 0x48, 0x73, 0x6c, 0x6f, 0x77, 0x20, 0x71, 0x75, 0x65, 0x72, 0x79,
+		0x22, 0x20, 0x6d, 0x73, 0x3d, 0x31, 0x35, 0x30, 0x30,
+	},
+}
+
+func TestLokiPushVector(t *testing.T) {
+	t.Parallel()
+
+	// the test decoder agrees with the reference encoder
+	dec, err := snappyDecode(lokiPushVector.snappy)
+	if err != nil || !bytes.Equal(dec, lokiPushVector.proto) {
+		t.Fatalf("wrong decoding of the reference snappy block: %v", err)
+	}
+	// a literal-only block, as the reference encoder writes inputs shorter than 17 bytes
+	if enc := snappyEncode([]byte("log15")); !bytes.Equal(enc, []byte{0x05, 0x10, 'l', 'o', 'g', '1', '5'}) {
+		t.Fatalf("wrong encoding of a short input: %v", enc)
+	}
+
+	info := `{level="info", logger="db"}`
+	streams := [][]lokiEntry{
+		{
+			{stream: info, time: time.Unix(1500000000, 123456789), line: `msg="query done" ms=12`},
+			{stream: info, time: time.Unix(1500000001, 0), line: `msg="query done" ms=7`},
+		},
+		{{stream: `{level="warn"}`, time: time.Unix(1500000000, 0), line: `msg="slow query" ms=1500`}},
+	}
+	dec, err = snappyDecode((&Loki{}).encodeProtobuf(streams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, lokiPushVector.proto) {
+		t.Fatalf("wrong push request encoding:\n%x\nexpected:\n%x", dec, lokiPushVector.proto)
+	}
+}
+
+type protoField struct {
+	num   int
+	value uint64
+	bytes []byte
+}
+
+// protoDecode decodes the fields of a protobuf message.
+func protoDecode(t *testing.T, b []byte) []protoField {
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.value, n = binary.Uvarint(b)
+			b = b[n:]
+		case 1:
+			f.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			f.bytes = b[n : n+int(l)]
+			b = b[n+int(l):]
+		case 5:
+			f.value = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+type lokiPushed struct {
+	stream string
+	time   time.Time
+	line   string
+}
+
+// lokiServer decodes the push requests. It responds with the error statuses,
+// where 0 accepts the request, then accepts all requests.
+func lokiServer(t *testing.T, statuses ...int) (*httptest.Server, chan lokiPushed) {
+	pushed := make(chan lokiPushed, 100)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if i := int(atomic.AddInt32(&calls, 1)) - 1; i < len(statuses) && statuses[i] != 0 {
+			w.WriteHeader(statuses[i])
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.Header.Get("Content-Type") {
+		case "application/json":
+			var req struct {
+				Streams []struct {
+					Stream map[string]string
+					Values [][2]string
+				}
+			}
+			if err := json.Unmarshal(body, &req); err != nil {
+				t.Error(err)
+			}
+			for _, s := range req.Streams {
+				for _, v := range s.Values {
+					var ns int64
+					json.Unmarshal([]byte(v[0]), &ns)
+					pushed <- lokiPushed{lokiStream(s.Stream), time.Unix(0, ns), v[1]}
+				}
+			}
+		case "application/x-protobuf":
+			b, err := snappyDecode(body)
+			if err != nil {
+				t.Error(err)
+			}
+			for _, s := range protoDecode(t, b) {
+				var stream string
+				for _, f := range protoDecode(t, s.bytes) {
+					if f.num == 1 {
+						stream = string(f.bytes)
+						continue
+					}
+					var p lokiPushed
+					p.stream = stream
+					for _, ef := range protoDecode(t, f.bytes) {
+						if ef.num == 2 {
+							p.line = string(ef.bytes)
+							continue
+						}
+						var sec, nsec uint64
+						for _, tf := range protoDecode(t, ef.bytes) {
+							if tf.num == 1 {
+								sec = tf.value
+							} else {
+								nsec = tf.value
+							}
+						}
+						p.time = time.Unix(int64(sec), int64(nsec))
+					}
+					pushed <- p
+				}
+			}
+		default:
+			t.Errorf("wrong content type %s", r.Header.Get("Content-Type"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return srv, pushed
+}
+
+func expectLokiPushed(t *testing.T, pushed chan lokiPushed, expected ...lokiPushed) {
+	for _, e := range expected {
+		select {
+		case p := <-pushed:
+			if p.stream != e.stream || p.line != e.line || !e.time.IsZero() && !p.time.Equal(e.time) {
+				t.Fatalf("wrong push %v, expected %v", p, e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %v", e)
+		}
+	}
+}
+
+func TestLokiHandler(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Unix(1500000000, 123456789)
+	for _, enc := range []LokiEncoding{LokiProtobuf, LokiJSON} {
+		srv, pushed := lokiServer(t)
+		h, err := LokiHandler(LokiConfig{
+			URL:       srv.URL,
+			Encoding:  enc,
+			Labels:    map[string]string{"job": "api"},
+			LabelKeys: []string{"region"},
+			Format:    FormatFunc(func(r *Record) []byte { return []byte(logfmtLine(r.Msg, r.Ctx) + "\n") }),
+			Batch:     BatchConfig{FlushInterval: time.Minute},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Log(&Record{Time: ts, Lvl: LvlInfo, Msg: "a", Ctx: []interface{}{"region", "eu", "user", "bob"}})
+		h.Log(&Record{Time: ts, Lvl: LvlWarn, Msg: "b", Name: "db", Ctx: []interface{}{"n", 1}})
+		h.Log(&Record{Time: ts, Lvl: LvlInfo, Msg: "c", Ctx: []interface{}{"region", "eu"}})
+		h.Flush()
+		expectLokiPushed(t, pushed,
+			lokiPushed{`{job="api", level="info", region="eu"}`, ts, `a user="bob"`},
+			lokiPushed{`{job="api", level="info", region="eu"}`, ts, "c"},
+			lokiPushed{`{job="api", level="warn", logger="db"}`, ts, "b n=1"},
+		)
+		h.Close()
+		srv.Close()
+	}
+
+	if _, err := LokiHandler(LokiConfig{URL: "localhost:3100"}); err == nil {
+		t.Fatalf("expected URL error")
+	}
+}
+
+// logfmtLine formats the message and the context in logfmt, without the time and level.
+func logfmtLine(msg string, ctx []interface{}) string {
+	var buf bytes.Buffer
+	Logfmt(&buf, ctx, 0)
+	if buf.Len() > 1 {
+		return msg + " " + buf.String()[:buf.Len()-1]
+	}
+	return msg
+}
+
+func TestLokiHandlerRetry(t *testing.T) {
+	t.Parallel()
+
+	srv, pushed := lokiServer(t, 503, 429, 0, 400)
+	defer srv.Close()
+	var errs int32
+	h := Must.LokiHandler(LokiConfig{
+		URL:    srv.URL,
+		Format: FormatFunc(func(r *Record) []byte { return []byte(r.Msg) }),
+		Batch: BatchConfig{BatchSize: 1, MinBackoff: time.Millisecond, OnError: func(err error) {
+			atomic.AddInt32(&errs, 1)
+		}},
+	})
+	defer h.Close()
+	h.Log(&Record{Msg: "retried"})
+	// the 400 status isn't retried
+	h.Log(&Record{Msg: "rejected"})
+	h.Log(&Record{Msg: "sent"})
+	h.Flush()
+	expectLokiPushed(t, pushed,
+		lokiPushed{stream: `{level="criti"}`, line: "retried"},
+		lokiPushed{stream: `{level="criti"}`, line: "sent"})
+	if n := atomic.LoadInt32(&errs); n != 3 {
+		t.Fatalf("wrong number of errors: %d", n)
+	}
+	if h.Dropped() != 1 {
+		t.Fatalf("wrong number of dropped records: %d", h.Dropped())
+	}
+}
+
+func TestLokiHandlerBuffer(t *testing.T) {
+	t.Parallel()
+
+	srv, pushed := lokiServer(t)
+	defer srv.Close()
+	h := Must.LokiHandler(LokiConfig{
+		URL:    srv.URL,
+		Format: FormatFunc(func(r *Record) []byte { return []byte(r.Msg) }),
+		Batch:  BatchConfig{BufferSize: 2, FlushInterval: time.Minute},
+	})
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		h.Log(&Record{Lvl: LvlInfo, Msg: msg})
+	}
+	if h.Dropped() != 2 {
+		t.Fatalf("wrong number of dropped records: %d", h.Dropped())
+	}
+	h.Close()
+	expectLokiPushed(t, pushed, lokiPushed{stream: `{level="info"}`, line: "a"},
+		lokiPushed{stream: `{level="info"}`, line: "b"})
+	if err := h.Log(&Record{Msg: "closed"}); err != ErrHandlerClosed {
+		t.Fatalf("expected ErrHandlerClosed, got %v", err)
+	}
+}
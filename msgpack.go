@@ -0,0 +1,320 @@
+package log15
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// msgpackWriter is a minimal MessagePack encoder, enough for the Fluentd Forward protocol.
+type msgpackWriter struct {
+	buf []byte
+}
+
+func (w *msgpackWriter) putNil() {
+	w.buf = append(w.buf, 0xc0)
+}
+
+func (w *msgpackWriter) putBool(v bool) {
+	if v {
+		w.buf = append(w.buf, 0xc3)
+	} else {
+		w.buf = append(w.buf, 0xc2)
+	}
+}
+
+func (w *msgpackWriter) putInt(v int64) {
+	switch {
+	case v >= 0:
+		w.putUint(uint64(v))
+	case v >= -32:
+		w.buf = append(w.buf, byte(v))
+	case v >= math.MinInt8:
+		w.buf = append(w.buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		w.buf = append(w.buf, 0xd1, 0, 0)
+		binary.BigEndian.PutUint16(w.buf[len(w.buf)-2:], uint16(v))
+	case v >= math.MinInt32:
+		w.buf = append(w.buf, 0xd2, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(w.buf[len(w.buf)-4:], uint32(v))
+	default:
+		w.buf = append(w.buf, 0xd3)
+		w.buf = appendUint64(w.buf, uint64(v))
+	}
+}
+
+func (w *msgpackWriter) putUint(v uint64) {
+	switch {
+	case v < 128:
+		w.buf = append(w.buf, byte(v))
+	case v <= math.MaxUint8:
+		w.buf = append(w.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		w.buf = append(w.buf, 0xcd, 0, 0)
+		binary.BigEndian.PutUint16(w.buf[len(w.buf)-2:], uint16(v))
+	case v <= math.MaxUint32:
+		w.buf = append(w.buf, 0xce, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(w.buf[len(w.buf)-4:], uint32(v))
+	default:
+		w.buf = append(w.buf, 0xcf)
+		w.buf = appendUint64(w.buf, v)
+	}
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], v)
+	return append(b, tmp[:]...)
+}
+
+func (w *msgpackWriter) putFloat(v float64) {
+	w.buf = append(w.buf, 0xcb)
+	w.buf = appendUint64(w.buf, math.Float64bits(v))
+}
+
+// putHeader writes the type byte of a variable length value.
+func (w *msgpackWriter) putHeader(n int, fix byte, fixMax int, c8, c16, c32 byte) {
+	switch {
+	case n <= fixMax:
+		w.buf = append(w.buf, fix|byte(n))
+	case c8 != 0 && n <= math.MaxUint8:
+		w.buf = append(w.buf, c8, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, c16, 0, 0)
+		binary.BigEndian.PutUint16(w.buf[len(w.buf)-2:], uint16(n))
+	default:
+		w.buf = append(w.buf, c32, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(w.buf[len(w.buf)-4:], uint32(n))
+	}
+}
+
+func (w *msgpackWriter) putString(s string) {
+	w.putHeader(len(s), 0xa0, 31, 0xd9, 0xda, 0xdb)
+	w.buf = append(w.buf, s...)
+}
+
+func (w *msgpackWriter) putBin(b []byte) {
+	w.putHeader(len(b), 0, -1, 0xc4, 0xc5, 0xc6)
+	w.buf = append(w.buf, b...)
+}
+
+func (w *msgpackWriter) putArray(n int) {
+	w.putHeader(n, 0x90, 15, 0, 0xdc, 0xdd)
+}
+
+func (w *msgpackWriter) putMap(n int) {
+	w.putHeader(n, 0x80, 15, 0, 0xde, 0xdf)
+}
+
+// putEventTime writes the Fluentd EventTime extension (type 0).
+func (w *msgpackWriter) putEventTime(t time.Time) {
+	w.buf = append(w.buf, 0xd7, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(w.buf[len(w.buf)-8:], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(w.buf[len(w.buf)-4:], uint32(t.Nanosecond()))
+}
+
+// putValue writes v, which must be one of the types returned by scalarValue.
+func (w *msgpackWriter) putValue(v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.putNil()
+	case bool:
+		w.putBool(v)
+	case int64:
+		w.putInt(v)
+	case uint64:
+		w.putUint(v)
+	case float64:
+		w.putFloat(v)
+	case []byte:
+		w.putBin(v)
+	case string:
+		w.putString(v)
+	default:
+		w.putString(fmt.Sprintf("%+v", v))
+	}
+}
+
+// scalarValue converts a context value to nil, bool, string, []byte, int64,
+// uint64 or float64, for the binary encodings.
+func scalarValue(v interface{}) interface{} {
+	switch v := formatShared(v).(type) {
+	case nil, bool, string, []byte:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	default:
+		return fmt.Sprintf("%+v", v)
+	}
+}
+
+var errMsgpack = errors.New("log15: malformed msgpack data")
+
+// msgpackRead decodes a single MessagePack value. Maps are decoded as
+// map[string]interface{}, extensions as []byte with the type in the first byte.
+func msgpackRead(r *bufio.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	readN := func(n int) ([]byte, error) {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	readLen := func(size int) (int, error) {
+		b, err := readN(size)
+		if err != nil {
+			return 0, err
+		}
+		switch size {
+		case 1:
+			return int(b[0]), nil
+		case 2:
+			return int(binary.BigEndian.Uint16(b)), nil
+		}
+		return int(binary.BigEndian.Uint32(b)), nil
+	}
+	readArray := func(n int) (interface{}, error) {
+		a := make([]interface{}, n)
+		for i := range a {
+			if a[i], err = msgpackRead(r); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	readMap := func(n int) (interface{}, error) {
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			k, err := msgpackRead(r)
+			if err != nil {
+				return nil, err
+			}
+			if m[fmt.Sprint(k)], err = msgpackRead(r); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	readStr := func(n int, err error) (interface{}, error) {
+		if err != nil {
+			return nil, err
+		}
+		b, err := readN(n)
+		return string(b), err
+	}
+	readBin := func(n int, err error) (interface{}, error) {
+		if err != nil {
+			return nil, err
+		}
+		return readN(n)
+	}
+	readExt := func(n int, err error) (interface{}, error) {
+		if err != nil {
+			return nil, err
+		}
+		return readN(n + 1)
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return readStr(int(c&0x1f), nil)
+	case c&0xf0 == 0x90:
+		return readArray(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return readMap(int(c & 0x0f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		return readBin(readLen(1 << (c - 0xc4)))
+	case 0xc7, 0xc8, 0xc9:
+		return readExt(readLen(1 << (c - 0xc7)))
+	case 0xca:
+		b, err := readN(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := readN(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		b, err := readN(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		var v uint64
+		for _, x := range b {
+			v = v<<8 | uint64(x)
+		}
+		return v, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (c - 0xd0)
+		b, err := readN(n)
+		if err != nil {
+			return nil, err
+		}
+		var v uint64
+		for _, x := range b {
+			v = v<<8 | uint64(x)
+		}
+		shift := uint(64 - 8*n)
+		return int64(v<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return readExt(1<<(c-0xd4), nil)
+	case 0xd9, 0xda, 0xdb:
+		return readStr(readLen(1 << (c - 0xd9)))
+	case 0xdc, 0xdd:
+		n, err := readLen(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readArray(n)
+	case 0xde, 0xdf:
+		n, err := readLen(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMap(n)
+	}
+	return nil, errMsgpack
+}
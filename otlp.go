@@ -0,0 +1,430 @@
+package log15
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLPEncoding is the encoding of the OTLP/HTTP export requests.
+type OTLPEncoding int
+
+// List of supported OTLP/HTTP encodings
+const (
+	// OTLPProtobuf sends binary protobuf requests.
+	OTLPProtobuf OTLPEncoding = iota
+	// OTLPJSON sends JSON requests.
+	OTLPJSON
+)
+
+// OTLPConfig configures OTLPHandler.
+type OTLPConfig struct {
+	// URL of the logs endpoint, eg: http://localhost:4318/v1/logs
+	URL string
+	// Encoding of the export requests.
+	Encoding OTLPEncoding
+	// ServiceName and ServiceVersion are the service.name and service.version
+	// resource attributes.
+	ServiceName, ServiceVersion string
+	// Resource attributes describe the source of the logs, eg: {"host.name": "web1"}.
+	Resource map[string]interface{}
+	// Header is added to the export requests, eg: for authorization.
+	Header http.Header
+	// Client sends the export requests. Default: a client with a 10s timeout.
+	Client *http.Client
+	// Batch configures batching and retries. The default BatchSize is 512.
+	Batch BatchConfig
+}
+
+// otlpSeverity maps Lvl to the OpenTelemetry severity number and
+// otlpSeverityText to its short name.
+var otlpSeverity = [6]int{21, 17, 13, 9, 5, 1}
+var otlpSeverityText = [6]string{"FATAL", "ERROR", "WARN", "INFO", "DEBUG", "TRACE"}
+
+// Context keys of the trace correlation, as added by the trace package.
+const (
+	otlpTraceIDKey    = "trace_id"
+	otlpSpanIDKey     = "span_id"
+	otlpTraceFlagsKey = "trace_flags"
+)
+
+// OTLPHandler returns a handler which exports records to an OpenTelemetry
+// collector with OTLP/HTTP. The records are converted to LogRecords:
+//
+//   - severity number and text are derived from the record level,
+//   - body is the record message,
+//   - trace_id, span_id and flags are the hex encoded trace_id, span_id and
+//     trace_flags context values, added by the trace package Handler,
+//   - attributes are the context key/value pairs. Errors logged without keys
+//     are the exception.message attribute and the stack traces of FancyErrors
+//     are exception.stacktrace. The call site is code.filepath, code.lineno and
+//     code.function,
+//   - instrumentation scope is the logger name.
+//
+// Records are sent asynchronously in batches. Requests failing with a network
+// error, 429 or 5xx status are retried with exponential backoff. Flush waits
+// for the logged records to be sent and Close sends the buffered records.
+func OTLPHandler(c OTLPConfig) (*OTLP, error) {
+	if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+		return nil, errors.New("Wrong `URL` value " + c.URL + ", should be a http(s) URL of the logs endpoint")
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if c.Batch.BatchSize <= 0 {
+		c.Batch.BatchSize = 512
+	}
+	h := &OTLP{c: c}
+	if c.ServiceName != "" {
+		h.resource = append(h.resource, otlpAttr{"service.name", c.ServiceName})
+	}
+	if c.ServiceVersion != "" {
+		h.resource = append(h.resource, otlpAttr{"service.version", c.ServiceVersion})
+	}
+	keys := make([]string, 0, len(c.Resource))
+	for k := range c.Resource {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h.resource = append(h.resource, otlpAttr{k, scalarValue(c.Resource[k])})
+	}
+	h.lazy = LazyHandler(FuncHandler(h.enqueue))
+	h.batcher = newBatcher(c.Batch.withDefaults(), nil, h.send)
+	return h, nil
+}
+
+// OTLP is the Handler returned by OTLPHandler.
+type OTLP struct {
+	*batcher
+	c        OTLPConfig
+	resource []otlpAttr
+	lazy     Handler
+}
+
+type otlpAttr struct {
+	key   string
+	value interface{} // one of the scalarValue types
+}
+
+type otlpRecord struct {
+	scope    string
+	time     time.Time
+	observed time.Time
+	severity int
+	sevText  string
+	body     string
+	attrs    []otlpAttr
+	traceID  []byte
+	spanID   []byte
+	flags    uint32
+}
+
+// Log implements Handler interface.
+func (h *OTLP) Log(r *Record) error {
+	return h.lazy.Log(r)
+}
+
+func (h *OTLP) enqueue(r *Record) error {
+	rec := otlpRecord{
+		scope:    r.Name,
+		time:     r.Time,
+		observed: time.Now(),
+		severity: 1,
+		sevText:  "TRACE",
+		body:     r.Msg,
+	}
+	if r.Lvl >= 0 && int(r.Lvl) < len(otlpSeverity) {
+		rec.severity = otlpSeverity[r.Lvl]
+		rec.sevText = otlpSeverityText[r.Lvl]
+	}
+	errs := walkCtx(r.Ctx, func(k string, v interface{}) {
+		if !rec.setTraceField(k, v) {
+			rec.attrs = append(rec.attrs, otlpAttr{k, scalarValue(v)})
+		}
+	})
+	var stacks []string
+	for _, err := range errs {
+		if e, ok := err.(FancyError); ok && !e.IsReq() {
+			stacks = append(stacks, e.Stacktrace().String())
+		}
+	}
+	if len(errs) > 0 {
+		rec.attrs = append(rec.attrs, otlpAttr{"exception.message", joinErrors(errs)})
+	}
+	if len(stacks) > 0 {
+		rec.attrs = append(rec.attrs, otlpAttr{"exception.stacktrace", strings.Join(stacks, "\n")})
+	}
+	if f := r.Call.Frame(); f.PC != 0 {
+		rec.attrs = append(rec.attrs, otlpAttr{"code.filepath", f.File},
+			otlpAttr{"code.lineno", int64(f.Line)}, otlpAttr{"code.function", f.Function})
+	}
+	return h.batcher.add(rec)
+}
+
+// setTraceField sets the trace correlation field of the k context key.
+// It returns false if k isn't such key or v isn't a valid hex value.
+func (rec *otlpRecord) setTraceField(k string, v interface{}) bool {
+	var n int
+	switch k {
+	case otlpTraceIDKey:
+		n = 16
+	case otlpSpanIDKey:
+		n = 8
+	case otlpTraceFlagsKey:
+		n = 1
+	default:
+		return false
+	}
+	s, ok := v.(string)
+	if !ok || len(s) != 2*n {
+		return false
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return false
+	}
+	switch k {
+	case otlpTraceIDKey:
+		rec.traceID = b
+	case otlpSpanIDKey:
+		rec.spanID = b
+	default:
+		rec.flags = uint32(b[0])
+	}
+	return true
+}
+
+// otlpScopes groups the records by scope, in the order of their first records.
+func otlpScopes(batch []interface{}) [][]otlpRecord {
+	var scopes [][]otlpRecord
+	idx := make(map[string]int)
+	for _, item := range batch {
+		rec := item.(otlpRecord)
+		i, ok := idx[rec.scope]
+		if !ok {
+			i = len(scopes)
+			idx[rec.scope] = i
+			scopes = append(scopes, nil)
+		}
+		scopes[i] = append(scopes[i], rec)
+	}
+	return scopes
+}
+
+func otlpUnixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+// encodeProtobuf encodes the ExportLogsServiceRequest message:
+//
+//     ExportLogsServiceRequest { repeated ResourceLogs resource_logs = 1; }
+//     ResourceLogs { Resource resource = 1; repeated ScopeLogs scope_logs = 2; }
+//     Resource { repeated KeyValue attributes = 1; }
+//     ScopeLogs { InstrumentationScope scope = 1; repeated LogRecord log_records = 2; }
+//     InstrumentationScope { string name = 1; }
+//     LogRecord { fixed64 time_unix_nano = 1; fixed64 observed_time_unix_nano = 11;
+//         SeverityNumber severity_number = 2; string severity_text = 3;
+//         AnyValue body = 5; repeated KeyValue attributes = 6; fixed32 flags = 8;
+//         bytes trace_id = 9; bytes span_id = 10; }
+func (h *OTLP) encodeProtobuf(scopes [][]otlpRecord) []byte {
+	w := &protoWriter{}
+	w.putMessage(1, func(w *protoWriter) {
+		w.putMessage(1, func(w *protoWriter) {
+			putOTLPAttrs(w, 1, h.resource)
+		})
+		for _, records := range scopes {
+			w.putMessage(2, func(w *protoWriter) {
+				w.putMessage(1, func(w *protoWriter) {
+					w.putString(1, records[0].scope)
+				})
+				for _, rec := range records {
+					w.putMessage(2, func(w *protoWriter) {
+						w.putFixed64(1, otlpUnixNano(rec.time))
+						w.putUint(2, uint64(rec.severity))
+						w.putString(3, rec.sevText)
+						w.putMessage(5, func(w *protoWriter) {
+							putOTLPValue(w, rec.body)
+						})
+						putOTLPAttrs(w, 6, rec.attrs)
+						w.putFixed32(8, rec.flags)
+						w.putBytes(9, rec.traceID)
+						w.putBytes(10, rec.spanID)
+						w.putFixed64(11, otlpUnixNano(rec.observed))
+					})
+				}
+			})
+		}
+	})
+	return w.buf
+}
+
+// putOTLPAttrs writes the repeated KeyValue { string key = 1; AnyValue value = 2; } field.
+func putOTLPAttrs(w *protoWriter, field int, attrs []otlpAttr) {
+	for _, a := range attrs {
+		w.putMessage(field, func(w *protoWriter) {
+			w.putString(1, a.key)
+			w.putMessage(2, func(w *protoWriter) {
+				putOTLPValue(w, a.value)
+			})
+		})
+	}
+}
+
+// putOTLPValue writes the field of the AnyValue oneof. Unlike the other fields,
+// they are written even if they have the default value.
+//
+//     AnyValue { oneof value { string string_value = 1; bool bool_value = 2;
+//         int64 int_value = 3; double double_value = 4; bytes bytes_value = 7; } }
+func putOTLPValue(w *protoWriter, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		// empty AnyValue
+	case bool:
+		w.putTag(2, 0)
+		if v {
+			w.putVarint(1)
+		} else {
+			w.putVarint(0)
+		}
+	case int64:
+		w.putTag(3, 0)
+		w.putVarint(uint64(v))
+	case uint64:
+		if v > math.MaxInt64 {
+			putOTLPValue(w, strconv.FormatUint(v, 10))
+			return
+		}
+		putOTLPValue(w, int64(v))
+	case float64:
+		w.putTag(4, 1)
+		w.buf = append(w.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint64(w.buf[len(w.buf)-8:], math.Float64bits(v))
+	case []byte:
+		w.putTag(7, 2)
+		w.putVarint(uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	case string:
+		w.putTag(1, 2)
+		w.putVarint(uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	default:
+		putOTLPValue(w, fmt.Sprintf("%+v", v))
+	}
+}
+
+// encodeJSON encodes the request in the OTLP JSON encoding, which is
+// the protobuf JSON mapping with lowerCamelCase field names.
+func (h *OTLP) encodeJSON(scopes [][]otlpRecord) ([]byte, error) {
+	type object = map[string]interface{}
+	scopeLogs := make([]object, len(scopes))
+	for i, records := range scopes {
+		logRecords := make([]object, len(records))
+		for j, rec := range records {
+			lr := object{
+				"observedTimeUnixNano": strconv.FormatUint(otlpUnixNano(rec.observed), 10),
+				"severityNumber":       rec.severity,
+				"severityText":         rec.sevText,
+				"body":                 otlpJSONValue(rec.body),
+				"attributes":           otlpJSONAttrs(rec.attrs),
+			}
+			if !rec.time.IsZero() {
+				lr["timeUnixNano"] = strconv.FormatUint(otlpUnixNano(rec.time), 10)
+			}
+			// the IDs are hex encoded in the OTLP JSON mapping, unlike other bytes
+			if rec.traceID != nil {
+				lr["traceId"] = hex.EncodeToString(rec.traceID)
+			}
+			if rec.spanID != nil {
+				lr["spanId"] = hex.EncodeToString(rec.spanID)
+			}
+			if rec.flags != 0 {
+				lr["flags"] = rec.flags
+			}
+			logRecords[j] = lr
+		}
+		scopeLogs[i] = object{"scope": object{"name": records[0].scope}, "logRecords": logRecords}
+	}
+	return json.Marshal(object{"resourceLogs": []object{{
+		"resource":  object{"attributes": otlpJSONAttrs(h.resource)},
+		"scopeLogs": scopeLogs,
+	}}})
+}
+
+func otlpJSONAttrs(attrs []otlpAttr) []map[string]interface{} {
+	kvs := make([]map[string]interface{}, len(attrs))
+	for i, a := range attrs {
+		kvs[i] = map[string]interface{}{"key": a.key, "value": otlpJSONValue(a.value)}
+	}
+	return kvs
+}
+
+// otlpJSONValue returns the AnyValue object. 64 bit integers are strings in
+// the protobuf JSON mapping, bytes are base64 encoded by encoding/json.
+func otlpJSONValue(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case nil:
+		return map[string]interface{}{}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case uint64:
+		if v > math.MaxInt64 {
+			return map[string]interface{}{"stringValue": strconv.FormatUint(v, 10)}
+		}
+		return map[string]interface{}{"intValue": strconv.FormatUint(v, 10)}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			// encoding/json doesn't support them, the mapping uses strings
+			return map[string]interface{}{"doubleValue": strconv.FormatFloat(v, 'g', -1, 64)}
+		}
+		return map[string]interface{}{"doubleValue": v}
+	case []byte:
+		return map[string]interface{}{"bytesValue": v}
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprintf("%+v", v)}
+}
+
+func (h *OTLP) send(batch []interface{}) ([]interface{}, int, error) {
+	scopes := otlpScopes(batch)
+	var body []byte
+	var err error
+	contentType := "application/x-protobuf"
+	if h.c.Encoding == OTLPJSON {
+		contentType = "application/json"
+		if body, err = h.encodeJSON(scopes); err != nil {
+			return nil, len(batch), err
+		}
+	} else {
+		body = h.encodeProtobuf(scopes)
+	}
+	if _, err := httpPost(h.c.Client, h.c.URL, contentType, body, h.c.Header); err != nil {
+		if isRetryable(err) {
+			return batch, 0, err
+		}
+		return nil, len(batch), err
+	}
+	return nil, 0, nil
+}
+
+func (m muster) OTLPHandler(c OTLPConfig) *OTLP {
+	h, err := OTLPHandler(c)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
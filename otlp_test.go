@@ -0,0 +1,325 @@
+package log15
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// otlpLog is a decoded LogRecord, with the values of AnyValues in the JSON
+// encoding format: {"stringValue": "x"}.
+type otlpLog struct {
+	resource map[string]interface{}
+	scope    string
+	time     uint64
+	severity int
+	sevText  string
+	body     interface{}
+	attrs    map[string]interface{}
+	traceID  string // hex encoded
+	spanID   string
+	flags    uint32
+}
+
+// otlpProtoValue decodes AnyValue to the JSON encoding format.
+func otlpProtoValue(t *testing.T, b []byte) interface{} {
+	for _, f := range protoDecode(t, b) {
+		switch f.num {
+		case 1:
+			return map[string]interface{}{"stringValue": string(f.bytes)}
+		case 2:
+			return map[string]interface{}{"boolValue": f.value == 1}
+		case 3:
+			return map[string]interface{}{"intValue": strconv.FormatInt(int64(f.value), 10)}
+		case 4:
+			return map[string]interface{}{"doubleValue": math.Float64frombits(f.value)}
+		}
+	}
+	return map[string]interface{}{}
+}
+
+func otlpProtoAttrs(t *testing.T, attrs map[string]interface{}, b []byte) {
+	var key string
+	var value interface{}
+	for _, f := range protoDecode(t, b) {
+		if f.num == 1 {
+			key = string(f.bytes)
+		} else {
+			value = otlpProtoValue(t, f.bytes)
+		}
+	}
+	attrs[key] = value
+}
+
+func decodeOTLPProtobuf(t *testing.T, b []byte) []otlpLog {
+	var logs []otlpLog
+	for _, rl := range protoDecode(t, b) {
+		resource := map[string]interface{}{}
+		for _, f := range protoDecode(t, rl.bytes) {
+			if f.num == 1 {
+				for _, a := range protoDecode(t, f.bytes) {
+					otlpProtoAttrs(t, resource, a.bytes)
+				}
+				continue
+			}
+			var scope string
+			for _, sf := range protoDecode(t, f.bytes) {
+				if sf.num == 1 {
+					for _, nf := range protoDecode(t, sf.bytes) {
+						scope = string(nf.bytes)
+					}
+					continue
+				}
+				l := otlpLog{resource: resource, scope: scope, attrs: map[string]interface{}{}}
+				for _, lf := range protoDecode(t, sf.bytes) {
+					switch lf.num {
+					case 1:
+						l.time = lf.value
+					case 2:
+						l.severity = int(lf.value)
+					case 3:
+						l.sevText = string(lf.bytes)
+					case 5:
+						l.body = otlpProtoValue(t, lf.bytes)
+					case 6:
+						otlpProtoAttrs(t, l.attrs, lf.bytes)
+					case 8:
+						l.flags = uint32(lf.value)
+					case 9:
+						l.traceID = hex.EncodeToString(lf.bytes)
+					case 10:
+						l.spanID = hex.EncodeToString(lf.bytes)
+					}
+				}
+				logs = append(logs, l)
+			}
+		}
+	}
+	return logs
+}
+
+func decodeOTLPJSON(t *testing.T, b []byte) []otlpLog {
+	type kv struct {
+		Key   string
+		Value interface{}
+	}
+	attrs := func(kvs []kv) map[string]interface{} {
+		m := map[string]interface{}{}
+		for _, a := range kvs {
+			m[a.Key] = a.Value
+		}
+		return m
+	}
+	var req struct {
+		ResourceLogs []struct {
+			Resource  struct{ Attributes []kv }
+			ScopeLogs []struct {
+				Scope      struct{ Name string }
+				LogRecords []struct {
+					TimeUnixNano   string
+					SeverityNumber int
+					SeverityText   string
+					Body           interface{}
+					Attributes     []kv
+					TraceID        string
+					SpanID         string
+					Flags          uint32
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(b, &req); err != nil {
+		t.Fatal(err)
+	}
+	var logs []otlpLog
+	for _, rl := range req.ResourceLogs {
+		for _, sl := range rl.ScopeLogs {
+			for _, lr := range sl.LogRecords {
+				ts, _ := strconv.ParseUint(lr.TimeUnixNano, 10, 64)
+				logs = append(logs, otlpLog{attrs(rl.Resource.Attributes), sl.Scope.Name, ts,
+					lr.SeverityNumber, lr.SeverityText, lr.Body, attrs(lr.Attributes),
+					lr.TraceID, lr.SpanID, lr.Flags})
+			}
+		}
+	}
+	return logs
+}
+
+// otlpReceiver decodes the export requests. It responds with the error
+// statuses first, then accepts all requests.
+func otlpReceiver(t *testing.T, statuses ...int) (*httptest.Server, chan otlpLog) {
+	logs := make(chan otlpLog, 100)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if i := int(atomic.AddInt32(&calls, 1)) - 1; i < len(statuses) {
+			w.WriteHeader(statuses[i])
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		var decoded []otlpLog
+		switch r.Header.Get("Content-Type") {
+		case "application/x-protobuf":
+			decoded = decodeOTLPProtobuf(t, body)
+		case "application/json":
+			decoded = decodeOTLPJSON(t, body)
+		default:
+			t.Errorf("wrong content type %s", r.Header.Get("Content-Type"))
+		}
+		for _, l := range decoded {
+			logs <- l
+		}
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+	}))
+	return srv, logs
+}
+
+func expectOTLPLog(t *testing.T, logs chan otlpLog) otlpLog {
+	select {
+	case l := <-logs:
+		return l
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for a log record")
+	}
+	return otlpLog{}
+}
+
+func otlpString(s string) map[string]interface{} {
+	return map[string]interface{}{"stringValue": s}
+}
+
+func TestOTLPHandler(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Unix(1500000000, 123456789)
+	for _, enc := range []OTLPEncoding{OTLPProtobuf, OTLPJSON} {
+		srv, logs := otlpReceiver(t)
+		h, err := OTLPHandler(OTLPConfig{URL: srv.URL, Encoding: enc, ServiceName: "api", ServiceVersion: "1.2.0",
+			Resource: map[string]interface{}{"host.name": "web1"}, Batch: BatchConfig{FlushInterval: time.Minute}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Log(&Record{Time: ts, Lvl: LvlWarn, Msg: "slow query", Name: "db", Ctx: []interface{}{
+			"ms", 1500, "ratio", 0.5, "ok", false, "user", "bob", errors.New("timeout"), CallerCtx("db.go:7"),
+			"trace_id", "4bf92f3577b34da6a3ce929d0e0e4736", "span_id", "00f067aa0ba902b7", "trace_flags", "01"}})
+		h.Log(&Record{Time: ts, Lvl: LvlCrit, Msg: "down"})
+		h.Flush()
+
+		l := expectOTLPLog(t, logs)
+		if l.scope != "db" || l.time != uint64(ts.UnixNano()) || l.severity != 13 || l.sevText != "WARN" {
+			t.Fatalf("wrong log record %+v", l)
+		}
+		if l.traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || l.spanID != "00f067aa0ba902b7" || l.flags != 1 {
+			t.Fatalf("wrong trace correlation %+v", l)
+		}
+		for _, k := range []string{"trace_id", "span_id", "trace_flags"} {
+			if _, ok := l.attrs[k]; ok {
+				t.Fatalf("unexpected %s attribute", k)
+			}
+		}
+		for k, v := range map[string]interface{}{"service.name": "api", "service.version": "1.2.0", "host.name": "web1"} {
+			if s, _ := l.resource[k].(map[string]interface{}); s["stringValue"] != v {
+				t.Fatalf("wrong %s resource attribute: %v", k, l.resource[k])
+			}
+		}
+		for k, v := range map[string]interface{}{
+			"ms":                map[string]interface{}{"intValue": "1500"},
+			"ratio":             map[string]interface{}{"doubleValue": 0.5},
+			"ok":                map[string]interface{}{"boolValue": false},
+			"user":              otlpString("bob"),
+			"exception.message": otlpString("timeout"),
+			"caller":            otlpString("db.go:7"),
+		} {
+			b1, _ := json.Marshal(l.attrs[k])
+			b2, _ := json.Marshal(v)
+			if string(b1) != string(b2) {
+				t.Fatalf("wrong %s attribute: %s, expected %s", k, b1, b2)
+			}
+		}
+		if b, _ := json.Marshal(l.body); string(b) != `{"stringValue":"slow query"}` {
+			t.Fatalf("wrong body %s", b)
+		}
+		l = expectOTLPLog(t, logs)
+		if l.scope != "" || l.severity != 21 || l.sevText != "FATAL" || l.traceID != "" || l.flags != 0 {
+			t.Fatalf("wrong log record %+v", l)
+		}
+		h.Close()
+		srv.Close()
+	}
+
+	if _, err := OTLPHandler(OTLPConfig{URL: "localhost:4318"}); err == nil {
+		t.Fatalf("expected URL error")
+	}
+}
+
+func TestOTLPHandlerRetry(t *testing.T) {
+	t.Parallel()
+
+	srv, logs := otlpReceiver(t, 503, 429)
+	defer srv.Close()
+	var errs int32
+	h := Must.OTLPHandler(OTLPConfig{URL: srv.URL, Batch: BatchConfig{MinBackoff: time.Millisecond,
+		OnError: func(err error) { atomic.AddInt32(&errs, 1) }}})
+	defer h.Close()
+	h.Log(&Record{Lvl: LvlInfo, Msg: "retried"})
+	h.Flush()
+	if l := expectOTLPLog(t, logs); l.severity != 9 {
+		t.Fatalf("wrong log record %+v", l)
+	}
+	if n := atomic.LoadInt32(&errs); n != 2 {
+		t.Fatalf("wrong number of errors: %d", n)
+	}
+}
+
+func TestOTLPHandlerRejected(t *testing.T) {
+	t.Parallel()
+
+	srv, logs := otlpReceiver(t, 400)
+	defer srv.Close()
+	var errs int32
+	h := Must.OTLPHandler(OTLPConfig{URL: srv.URL, Batch: BatchConfig{MinBackoff: time.Millisecond,
+		OnError: func(err error) { atomic.AddInt32(&errs, 1) }}})
+	defer h.Close()
+	// the 400 status isn't retried
+	h.Log(&Record{Lvl: LvlInfo, Msg: "rejected"})
+	h.Log(&Record{Lvl: LvlInfo, Msg: "rejected too"})
+	h.Flush()
+	h.Log(&Record{Lvl: LvlWarn, Msg: "sent"})
+	h.Flush()
+	if l := expectOTLPLog(t, logs); l.severity != 13 {
+		t.Fatalf("wrong log record %+v", l)
+	}
+	if n := atomic.LoadInt32(&errs); n != 1 {
+		t.Fatalf("wrong number of errors: %d", n)
+	}
+	if h.Dropped() != 2 {
+		t.Fatalf("wrong number of dropped records: %d", h.Dropped())
+	}
+}
+
+func TestPutOTLPValue(t *testing.T) {
+	t.Parallel()
+
+	// the oneof fields are written even with the default values
+	for _, v := range []interface{}{false, int64(0), 0.0, "", int64(-1), uint64(math.MaxUint64)} {
+		w := &protoWriter{}
+		putOTLPValue(w, v)
+		fields := protoDecode(t, w.buf)
+		if len(fields) != 1 {
+			t.Fatalf("wrong encoding of %v: %v", v, w.buf)
+		}
+		if v == int64(-1) && fields[0].value != math.MaxUint64 {
+			t.Fatalf("wrong encoding of -1: %v", w.buf)
+		}
+		if _, ok := v.(uint64); ok && string(fields[0].bytes) != "18446744073709551615" {
+			t.Fatalf("wrong encoding of MaxUint64: %v", w.buf)
+		}
+	}
+}
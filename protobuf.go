@@ -0,0 +1,83 @@
+package log15
+
+import "encoding/binary"
+
+// protoWriter is a minimal Protocol Buffers encoder, enough for the Loki
+// and OpenTelemetry push requests. Fields with default values are skipped,
+// like the generated encoders do.
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) putVarint(v uint64) {
+	for v >= 0x80 {
+		w.buf = append(w.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *protoWriter) putTag(field int, wireType byte) {
+	w.putVarint(uint64(field)<<3 | uint64(wireType))
+}
+
+// putUint writes a varint field (uint32, uint64, int32, int64 and enums).
+func (w *protoWriter) putUint(field int, v uint64) {
+	if v != 0 {
+		w.putTag(field, 0)
+		w.putVarint(v)
+	}
+}
+
+func (w *protoWriter) putFixed64(field int, v uint64) {
+	if v != 0 {
+		w.putTag(field, 1)
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], v)
+		w.buf = append(w.buf, b[:]...)
+	}
+}
+
+func (w *protoWriter) putFixed32(field int, v uint32) {
+	if v != 0 {
+		w.putTag(field, 5)
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], v)
+		w.buf = append(w.buf, b[:]...)
+	}
+}
+
+func (w *protoWriter) putBytes(field int, b []byte) {
+	if len(b) != 0 {
+		w.putTag(field, 2)
+		w.putVarint(uint64(len(b)))
+		w.buf = append(w.buf, b...)
+	}
+}
+
+func (w *protoWriter) putString(field int, s string) {
+	if s != "" {
+		w.putTag(field, 2)
+		w.putVarint(uint64(len(s)))
+		w.buf = append(w.buf, s...)
+	}
+}
+
+// putMessage writes the embedded message encoded by fn. Empty messages are
+// written too, as their presence can matter.
+func (w *protoWriter) putMessage(field int, fn func(w *protoWriter)) {
+	w.putTag(field, 2)
+	// reserve a byte for the length, the common case
+	start := len(w.buf)
+	w.buf = append(w.buf, 0)
+	fn(w)
+	n := len(w.buf) - start - 1
+	if n < 0x80 {
+		w.buf[start] = byte(n)
+		return
+	}
+	msg := append([]byte(nil), w.buf[start+1:]...)
+	w.buf = w.buf[:start]
+	w.putVarint(uint64(n))
+	w.buf = append(w.buf, msg...)
+}
@@ -0,0 +1,446 @@
+package log15
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReconnectConfig configures ReconnectingNetHandler.
+type ReconnectConfig struct {
+	// DialTimeout limits each connection attempt. Default: 10s.
+	DialTimeout time.Duration
+	// WriteTimeout limits each write to the connection. Default: 10s.
+	WriteTimeout time.Duration
+	// MinBackoff is the delay after the first failed connection attempt.
+	// The delay doubles with each next failure, up to MaxBackoff.
+	// Default: 100ms and 30s.
+	MinBackoff, MaxBackoff time.Duration
+	// BufferSize is the number of records kept in memory while the connection
+	// is down. Default: 1000.
+	BufferSize int
+	// SpoolPath is the file which stores records which don't fit the memory buffer.
+	// The records left there when the handler is closed are sent by the next
+	// handler using the same file. If empty, records which don't fit are dropped.
+	SpoolPath string
+	// MaxSpoolSize limits the size of the spool file in bytes. Zero means no limit.
+	MaxSpoolSize int64
+	// OnError is called with connection and spool errors.
+	OnError func(err error)
+	// TLSConfig enables TLS connections with the given configuration.
+	TLSConfig *tls.Config
+}
+
+func (c ReconnectConfig) withDefaults() ReconnectConfig {
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = 10 * time.Second
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 10 * time.Second
+	}
+	c.MinBackoff, c.MaxBackoff = backoffDefaults(c.MinBackoff, c.MaxBackoff)
+	if c.BufferSize <= 0 {
+		c.BufferSize = 1000
+	}
+	return c
+}
+
+// backoffDefaults returns the backoff delays with the defaults set:
+// 100ms and 30s, or min if it's larger.
+func backoffDefaults(min, max time.Duration) (time.Duration, time.Duration) {
+	if min <= 0 {
+		min = 100 * time.Millisecond
+	}
+	if max < min {
+		max = 30 * time.Second
+		if max < min {
+			max = min
+		}
+	}
+	return min, max
+}
+
+// dialer returns the function connecting to addr with DialTimeout,
+// over TLS if TLSConfig is set.
+func (c ReconnectConfig) dialer(network, addr string) func() (net.Conn, error) {
+	d := &net.Dialer{Timeout: c.DialTimeout}
+	return func() (net.Conn, error) {
+		if c.TLSConfig != nil {
+			return tls.DialWithDialer(d, network, addr, c.TLSConfig)
+		}
+		return d.Dial(network, addr)
+	}
+}
+
+// ReconnectingNetHandler returns a handler which writes records formatted with
+// fmtr to the given network address, like NetHandler. It connects in the background
+// and reconnects with exponential backoff whenever the connection fails.
+// Meanwhile records wait in a bounded memory buffer and, when c.SpoolPath is set,
+// in a spool file. They are sent in order once the connection is back.
+//
+// Log never blocks on the network. A record may be sent twice when the connection
+// breaks during its write. The error is returned only when opening the spool file fails.
+func ReconnectingNetHandler(network, addr string, fmtr Format, c ReconnectConfig) (*ReconnectingNet, error) {
+	c = c.withDefaults()
+	return newReconnectingNet(c.dialer(network, addr), isStreamNetwork(network), fmtr, c)
+}
+
+func newReconnectingNet(dial func() (net.Conn, error), stream bool, fmtr Format, c ReconnectConfig) (*ReconnectingNet, error) {
+	h := &ReconnectingNet{c: c, fmtr: fmtr, dial: dial, stream: stream, done: make(chan struct{}), stop: make(chan struct{})}
+	h.lazy = LazyHandler(FuncHandler(h.enqueue))
+	h.cond = sync.NewCond(&h.mu)
+	if c.SpoolPath != "" {
+		if err := h.openSpool(); err != nil {
+			return nil, err
+		}
+	}
+	go h.loop()
+	return h, nil
+}
+
+// ReconnectingNet is the Handler returned by ReconnectingNetHandler.
+type ReconnectingNet struct {
+	c    ReconnectConfig
+	fmtr Format
+	dial func() (net.Conn, error)
+	lazy Handler
+	// stream connections are watched for the server closing them
+	stream bool
+	done   chan struct{} // closed when the loop ends
+	stop   chan struct{} // closed by Close, interrupts the backoff
+
+	mu         sync.Mutex
+	cond       *sync.Cond // signals any change of the fields below
+	mem        [][]byte   // records waiting in memory, older than the spooled ones
+	spool      *os.File
+	spoolR     int64 // offset of the oldest spooled record
+	spoolW     int64 // size of the spool file
+	enqueued   uint64
+	sent       uint64 // number of enqueued records which were sent or dropped
+	failures   uint64
+	dropped    uint64
+	connected  bool
+	peerClosed net.Conn // the last connection closed by the server
+	closed     bool
+	errs       []error // reported errors waiting for OnError
+}
+
+// Log implements Handler interface.
+func (h *ReconnectingNet) Log(r *Record) error {
+	return h.lazy.Log(r)
+}
+
+func (h *ReconnectingNet) enqueue(r *Record) error {
+	b := h.fmtr.Format(r)
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrHandlerClosed
+	}
+	var err error
+	switch {
+	case h.spoolR == h.spoolW && len(h.mem) < h.c.BufferSize:
+		h.mem = append(h.mem, b)
+	case h.spool != nil && (h.c.MaxSpoolSize <= 0 || h.spoolW+int64(4+len(b)) <= h.c.MaxSpoolSize):
+		err = h.appendSpool(b)
+	default:
+		h.dropped++
+		h.mu.Unlock()
+		return nil
+	}
+	if err == nil {
+		h.enqueued++
+		h.cond.Broadcast()
+	} else {
+		h.dropped++
+	}
+	h.mu.Unlock()
+	if err != nil && h.c.OnError != nil {
+		h.c.OnError(err)
+	}
+	return nil
+}
+
+// openSpool opens the spool file and validates the records left in it.
+func (h *ReconnectingNet) openSpool() error {
+	f, err := os.OpenFile(h.c.SpoolPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	h.spool = f
+	// drop the incomplete record written when the previous process crashed
+	var hdr [4]byte
+	var off int64
+	for off+4 <= info.Size() {
+		if _, err := f.ReadAt(hdr[:], off); err != nil {
+			break
+		}
+		n := int64(binary.BigEndian.Uint32(hdr[:]))
+		if off+4+n > info.Size() {
+			break
+		}
+		off += 4 + n
+		h.enqueued++
+	}
+	h.spoolW = off
+	return f.Truncate(off)
+}
+
+// appendSpool writes b at the end of the spool file. It must be called with h.mu held.
+func (h *ReconnectingNet) appendSpool(b []byte) error {
+	buf := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(buf, uint32(len(b)))
+	copy(buf[4:], b)
+	if _, err := h.spool.WriteAt(buf, h.spoolW); err != nil {
+		return err
+	}
+	h.spoolW += int64(len(buf))
+	return nil
+}
+
+// peek returns the oldest waiting record. It must be called with h.mu held.
+func (h *ReconnectingNet) peek() ([]byte, error) {
+	if len(h.mem) > 0 {
+		return h.mem[0], nil
+	}
+	var hdr [4]byte
+	if _, err := h.spool.ReadAt(hdr[:], h.spoolR); err != nil {
+		return nil, err
+	}
+	b := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+	_, err := h.spool.ReadAt(b, h.spoolR+4)
+	return b, err
+}
+
+// pop removes the oldest waiting record. It must be called with h.mu held.
+func (h *ReconnectingNet) pop(n int) {
+	h.sent++
+	h.cond.Broadcast()
+	if len(h.mem) > 0 {
+		h.mem[0] = nil
+		h.mem = h.mem[1:]
+		return
+	}
+	h.spoolR += int64(4 + n)
+	if h.spoolR >= h.spoolW {
+		h.spoolR, h.spoolW = 0, 0
+		h.report(h.spool.Truncate(0))
+	}
+}
+
+func (h *ReconnectingNet) waiting() bool {
+	return len(h.mem) > 0 || h.spoolR < h.spoolW
+}
+
+// report saves the error for OnError. It must be called with h.mu held.
+func (h *ReconnectingNet) report(err error) {
+	if err != nil && h.c.OnError != nil {
+		h.errs = append(h.errs, err)
+	}
+}
+
+// unlock releases h.mu and passes the reported errors to OnError, so it can log
+// to the handler.
+func (h *ReconnectingNet) unlock() {
+	errs := h.errs
+	h.errs = nil
+	h.mu.Unlock()
+	for _, err := range errs {
+		h.c.OnError(err)
+	}
+}
+
+func (h *ReconnectingNet) loop() {
+	defer close(h.done)
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	backoff := h.c.MinBackoff
+	h.mu.Lock()
+	defer h.unlock()
+	for {
+		for !h.waiting() && !h.closed {
+			h.cond.Wait()
+		}
+		if !h.waiting() {
+			return
+		}
+		b, err := h.peek()
+		if err != nil {
+			// the spool file is broken, drop the rest of it (memory is empty here)
+			h.report(err)
+			h.sent = h.enqueued
+			h.spoolR, h.spoolW = 0, 0
+			h.report(h.spool.Truncate(0))
+			h.cond.Broadcast()
+			continue
+		}
+		closed := h.closed
+		h.unlock()
+
+		if conn == nil {
+			conn, err = h.dial()
+			if err == nil {
+				backoff = h.c.MinBackoff
+				if h.stream {
+					go h.watchConn(conn)
+				}
+			}
+		}
+		if conn != nil {
+			conn.SetWriteDeadline(time.Now().Add(h.c.WriteTimeout))
+			if _, err = conn.Write(b); err != nil {
+				conn.Close()
+				conn = nil
+			}
+		}
+
+		h.mu.Lock()
+		if err == nil {
+			// watchConn may have noticed the closed connection already
+			h.connected = h.peerClosed != conn
+			h.pop(len(b))
+			continue
+		}
+		h.connected = false
+		h.failures++
+		h.cond.Broadcast()
+		h.report(err)
+		if closed {
+			return
+		}
+		h.unlock()
+		select {
+		case <-time.After(backoff):
+		case <-h.stop:
+		}
+		if backoff *= 2; backoff > h.c.MaxBackoff {
+			backoff = h.c.MaxBackoff
+		}
+		h.mu.Lock()
+	}
+}
+
+// watchConn closes the connection when the server closes it, so the next
+// write fails instead of being lost in the socket buffer.
+func (h *ReconnectingNet) watchConn(conn net.Conn) {
+	_, err := io.Copy(ioutil.Discard, conn)
+	conn.Close()
+	if err != nil {
+		// closed by the handler
+		return
+	}
+	h.mu.Lock()
+	h.connected = false
+	h.peerClosed = conn
+	h.report(errClosedByPeer)
+	h.unlock()
+}
+
+// errClosedByPeer is reported when the server closes the connection.
+var errClosedByPeer = errors.New("log15: connection closed by the server")
+
+// Connected reports whether the last write or connection attempt succeeded
+// and the server didn't close the connection since.
+func (h *ReconnectingNet) Connected() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.connected
+}
+
+// Dropped returns the number of records dropped because the buffer
+// and the spool file were full.
+func (h *ReconnectingNet) Dropped() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.dropped
+}
+
+// errNotConnected is returned by Flush when the records can't be sent.
+var errNotConnected = errors.New("log15: connection is down, records are waiting in the buffer")
+
+// Flush waits until all records logged before the call are sent. It returns
+// an error if a connection attempt or a write fails in the meantime.
+func (h *ReconnectingNet) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	target, failures := h.enqueued, h.failures
+	for h.sent < target && h.failures == failures && !h.closed {
+		h.cond.Wait()
+	}
+	if h.sent < target {
+		return errNotConnected
+	}
+	return nil
+}
+
+// Close sends the waiting records, if the connection works, and closes it.
+// Records which can't be sent are saved in the spool file, if configured.
+func (h *ReconnectingNet) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.cond.Broadcast()
+	h.mu.Unlock()
+	close(h.stop)
+	<-h.done
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.spool == nil {
+		return nil
+	}
+	err := h.saveSpool()
+	if cerr := h.spool.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// saveSpool rewrites the spool file with all waiting records, in order.
+func (h *ReconnectingNet) saveSpool() error {
+	rest := make([]byte, h.spoolW-h.spoolR)
+	if _, err := h.spool.ReadAt(rest, h.spoolR); err != nil {
+		return err
+	}
+	h.spoolR, h.spoolW = 0, 0
+	if err := h.spool.Truncate(0); err != nil {
+		return err
+	}
+	for _, b := range h.mem {
+		if err := h.appendSpool(b); err != nil {
+			return err
+		}
+	}
+	h.mem = nil
+	if _, err := h.spool.WriteAt(rest, h.spoolW); err != nil {
+		return err
+	}
+	h.spoolW += int64(len(rest))
+	return nil
+}
+
+func (m muster) ReconnectingNetHandler(network, addr string, fmtr Format, c ReconnectConfig) *ReconnectingNet {
+	h, err := ReconnectingNetHandler(network, addr, fmtr, c)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
@@ -0,0 +1,207 @@
+package log15
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var msgFormat = FormatFunc(func(r *Record) []byte {
+	return []byte(r.Msg + "\n")
+})
+
+// freeAddr returns a local TCP address with no listener.
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// readLines accepts connections on ln and sends the received lines to the channel.
+// Each connection is closed after closeAfter lines, if it's positive.
+func readLines(ln net.Listener, closeAfter int) <-chan string {
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s := bufio.NewScanner(conn)
+			for n := 1; s.Scan(); n++ {
+				lines <- s.Text()
+				if n == closeAfter {
+					break
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return lines
+}
+
+// peerCloses returns the OnError callback which passes the connections closed
+// by the server to the returned channel.
+func peerCloses() (func(error), chan struct{}) {
+	closes := make(chan struct{}, 100)
+	return func(err error) {
+		if err == errClosedByPeer {
+			closes <- struct{}{}
+		}
+	}, closes
+}
+
+// expectPeerClose waits until the handler notices that the server closed the connection.
+func expectPeerClose(t *testing.T, closes chan struct{}) {
+	select {
+	case <-closes:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the closed connection")
+	}
+}
+
+func expectLines(t *testing.T, lines <-chan string, expected ...string) {
+	for _, e := range expected {
+		select {
+		case l := <-lines:
+			if l != e {
+				t.Fatalf("expected %q, got %q", e, l)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", e)
+		}
+	}
+}
+
+func logN(h Handler, from, to int) {
+	for i := from; i < to; i++ {
+		h.Log(&Record{Msg: strconv.Itoa(i)})
+	}
+}
+
+func TestReconnectingNetLateServer(t *testing.T) {
+	t.Parallel()
+
+	addr := freeAddr(t)
+	h, err := ReconnectingNetHandler("tcp", addr, msgFormat, ReconnectConfig{MinBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	logN(h, 0, 3)
+	if err := h.Flush(); err == nil {
+		t.Fatalf("expected Flush error without server")
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := readLines(ln, 0)
+	expectLines(t, lines, "0", "1", "2")
+	logN(h, 3, 5)
+	expectLines(t, lines, "3", "4")
+	if !h.Connected() {
+		t.Fatalf("expected connected handler")
+	}
+}
+
+func TestReconnectingNetReconnect(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := readLines(ln, 1)
+	onError, closes := peerCloses()
+	h, err := ReconnectingNetHandler("tcp", ln.Addr().String(), msgFormat,
+		ReconnectConfig{MinBackoff: 10 * time.Millisecond, OnError: onError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	for i := 0; i < 5; i++ {
+		logN(h, i, i+1)
+		expectLines(t, lines, strconv.Itoa(i))
+		expectPeerClose(t, closes)
+		if h.Connected() {
+			t.Fatalf("expected disconnected handler")
+		}
+	}
+}
+
+func TestReconnectingNetSpool(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "log15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spool := filepath.Join(dir, "spool")
+	c := ReconnectConfig{MinBackoff: 10 * time.Millisecond, BufferSize: 2, SpoolPath: spool}
+
+	addr := freeAddr(t)
+	h, err := ReconnectingNetHandler("tcp", addr, msgFormat, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logN(h, 0, 6)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(spool); err != nil || info.Size() != 6*(4+2) {
+		t.Fatalf("expected 6 spooled records, got: %v %v", info.Size(), err)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := readLines(ln, 0)
+	h, err = ReconnectingNetHandler("tcp", addr, msgFormat, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logN(h, 6, 10)
+	expectLines(t, lines, "0", "1", "2", "3", "4", "5", "6", "7", "8", "9")
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(spool); err != nil || info.Size() != 0 {
+		t.Fatalf("expected empty spool file, got: %v %v", info.Size(), err)
+	}
+}
+
+func TestReconnectingNetDropped(t *testing.T) {
+	t.Parallel()
+
+	h, err := ReconnectingNetHandler("tcp", freeAddr(t), msgFormat, ReconnectConfig{BufferSize: 3, MinBackoff: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logN(h, 0, 10)
+	if h.Dropped() != 7 {
+		t.Fatalf("expected 7 dropped records, got %d", h.Dropped())
+	}
+	h.Close()
+	if err := h.Log(&Record{}); err != ErrHandlerClosed {
+		t.Fatalf("expected ErrHandlerClosed, got %v", err)
+	}
+}
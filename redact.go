@@ -0,0 +1,434 @@
+package log15
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RedactMode defines how RedactHandler replaces sensitive values.
+type RedactMode int
+
+// List of supported redaction modes
+const (
+	// RedactMask replaces the value with RedactConfig.Mask.
+	RedactMask RedactMode = iota
+	// RedactHash replaces the value with a truncated, salted SHA-256 hash,
+	// so equal values can still be correlated.
+	RedactHash
+	// RedactPartial masks all but the last RedactConfig.Reveal characters.
+	RedactPartial
+)
+
+// Common patterns of sensitive values to use in RedactConfig.Values.
+var (
+	CreditCardPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	JWTPattern        = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	EmailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// RedactConfig configures RedactHandler.
+type RedactConfig struct {
+	// Keys lists context keys whose values are redacted. Keys are matched case
+	// insensitive, either exactly or as path.Match glob patterns (eg: "*password*").
+	// The string keys of map values are matched as well.
+	Keys []string
+	// KeyPatterns are regular expressions matched against context keys.
+	KeyPatterns []*regexp.Regexp
+	// Values are regular expressions matched against the record message and
+	// the string, number (in decimal), error and fmt.Stringer values, also when
+	// they are elements of slices, arrays and maps or exported struct fields.
+	// Only the matched parts of the top level values are redacted, the other
+	// strings are redacted in place too, numbers are zeroed and a nested error
+	// or fmt.Stringer makes the whole value replaced.
+	Values []*regexp.Regexp
+	// StructTag enables redaction of exported struct fields tagged with
+	// `<StructTag>:"redact"`, including Spew and Alone values. Unexported fields
+	// can't be modified, so values with a tagged unexported field are replaced
+	// whole, and untagged unexported fields aren't checked against Values.
+	// Values are checked up to 8 levels deep. The logged value is a copy,
+	// the original value is not modified.
+	StructTag string
+	// Mode defines the replacement of redacted values.
+	Mode RedactMode
+	// Mask replaces values in RedactMask and RedactPartial mode. Default: "[REDACTED]"
+	// for RedactMask and "*" (repeated) for RedactPartial.
+	Mask string
+	// Reveal is the number of trailing characters kept by RedactPartial.
+	Reveal int
+	// Salt is prepended to values hashed in RedactHash mode.
+	Salt string
+}
+
+// RedactHandler returns a Handler which redacts secrets and personal data
+// from records before passing them to h. Values are redacted by the context key
+// name, by the value patterns and by struct tags, according to the config:
+//
+//     h = log.RedactHandler(log.RedactConfig{
+//         Keys:   []string{"*password*", "authorization", "*token*"},
+//         Values: []*regexp.Regexp{log.CreditCardPattern, log.JWTPattern},
+//         Mode:   log.RedactPartial,
+//         Reveal: 4,
+//     }, h)
+//
+// The record passed to h is a copy, so other handlers logging the same record
+// (eg: in MultiHandler) are not affected.
+func RedactHandler(c RedactConfig, h Handler) Handler {
+	rd := &redactor{c: c}
+	for _, k := range c.Keys {
+		rd.keys = append(rd.keys, strings.ToLower(k))
+	}
+	// lazy values are evaluated first, to be checked against the value patterns
+	return LazyHandler(passFuncHandler(func(r *Record) error {
+		return h.Log(rd.record(r))
+	}, h))
+}
+
+type redactor struct {
+	c     RedactConfig
+	keys  []string
+	types sync.Map // reflect.Type -> bool, whether the type may need redaction
+}
+
+func (rd *redactor) record(r *Record) *Record {
+	rc := *r
+	rc.Msg = rd.redactString(r.Msg)
+	rc.Ctx = make([]interface{}, len(r.Ctx))
+	for i := 0; i < len(r.Ctx); i++ {
+		switch v := r.Ctx[i].(type) {
+		case string:
+			rc.Ctx[i] = v
+			if i+1 < len(r.Ctx) {
+				i++
+				if rd.matchKey(v) {
+					rc.Ctx[i] = rd.replaceValue(r.Ctx[i])
+				} else {
+					rc.Ctx[i] = rd.value(r.Ctx[i], 0)
+				}
+			}
+		case SpewWrapper:
+			if v.Msg != "" && rd.matchKey(v.Msg) {
+				v.Obj = rd.replaceValue(v.Obj)
+			} else {
+				v.Obj = rd.value(v.Obj, 0)
+			}
+			rc.Ctx[i] = v
+		case aloneWrapper:
+			if rd.matchKey(v.title) {
+				v.obj = rd.replaceValue(v.obj)
+			} else {
+				v.obj = rd.value(v.obj, 0)
+			}
+			rc.Ctx[i] = v
+		default:
+			rc.Ctx[i] = rd.value(v, 0)
+		}
+	}
+	return &rc
+}
+
+func (rd *redactor) matchKey(k string) bool {
+	lk := strings.ToLower(k)
+	for _, p := range rd.keys {
+		if p == lk {
+			return true
+		}
+		if ok, _ := path.Match(p, lk); ok {
+			return true
+		}
+	}
+	for _, re := range rd.c.KeyPatterns {
+		if re.MatchString(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// value redacts the parts of v which match the value patterns, the keys of its
+// maps or its struct tags. See RedactConfig.Values for what is covered.
+func (rd *redactor) value(v interface{}, depth int) interface{} {
+	switch vt := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return rd.redactString(vt)
+	case error:
+		if s := vt.Error(); rd.matchValue(s) {
+			return errors.New(rd.redactString(s))
+		}
+		return v
+	case fmt.Stringer:
+		if s := vt.String(); rd.matchValue(s) {
+			return rd.redactString(s)
+		}
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	if s, ok := numberString(rv); ok {
+		if rd.matchValue(s) {
+			return rd.redactString(s)
+		}
+		return v
+	}
+	if !rd.walks(rv.Type(), depth) {
+		return v
+	}
+	rc, ok := rd.redact(rv, depth)
+	if !ok {
+		return rd.replaceValue(v)
+	}
+	return rc.Interface()
+}
+
+func (rd *redactor) matchValue(s string) bool {
+	for _, re := range rd.c.Values {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rd *redactor) redactString(s string) string {
+	for _, re := range rd.c.Values {
+		s = re.ReplaceAllStringFunc(s, rd.replace)
+	}
+	return s
+}
+
+// replaceValue returns the replacement of the whole value.
+func (rd *redactor) replaceValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return rd.replace(fmt.Sprint(v))
+}
+
+func (rd *redactor) replace(s string) string {
+	switch rd.c.Mode {
+	case RedactHash:
+		sum := sha256.Sum256([]byte(rd.c.Salt + s))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	case RedactPartial:
+		mask := rd.c.Mask
+		if mask == "" {
+			mask = "*"
+		}
+		rs := []rune(s)
+		n := rd.c.Reveal
+		if n > len(rs)/2 {
+			n = len(rs) / 2
+		}
+		if n < 0 {
+			n = 0
+		}
+		return strings.Repeat(mask, len(rs)-n) + string(rs[len(rs)-n:])
+	}
+	if rd.c.Mask == "" {
+		return "[REDACTED]"
+	}
+	return rd.c.Mask
+}
+
+// maxRedactDepth limits the nesting of values checked for redaction.
+const maxRedactDepth = 8
+
+var (
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// numberString returns the decimal representation of the integer or float v.
+func numberString(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// walks reports whether values of the type t may contain something to redact,
+// so redact has to copy them.
+func (rd *redactor) walks(t reflect.Type, depth int) bool {
+	if depth > maxRedactDepth {
+		return false
+	}
+	if has, ok := rd.types.Load(t); ok {
+		return has.(bool)
+	}
+	// a recursive type is assumed not to need redaction until it's checked
+	rd.types.Store(t, false)
+	var has bool
+	switch {
+	case t.Implements(errorType) || t.Implements(stringerType):
+		has = len(rd.c.Values) > 0
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			has = len(rd.c.Values) > 0
+		case reflect.Interface:
+			has = true
+		case reflect.Ptr, reflect.Array:
+			has = rd.walks(t.Elem(), depth+1)
+		case reflect.Slice:
+			// byte slices aren't printed as numbers
+			has = t.Elem().Kind() != reflect.Uint8 && rd.walks(t.Elem(), depth+1)
+		case reflect.Map:
+			has = t.Key().Kind() == reflect.String && len(rd.keys)+len(rd.c.KeyPatterns) > 0 ||
+				rd.walks(t.Elem(), depth+1)
+		case reflect.Struct:
+			for i := 0; i < t.NumField() && !has; i++ {
+				f := t.Field(i)
+				has = rd.tagged(f) || f.PkgPath == "" && rd.walks(f.Type, depth+1)
+			}
+		default:
+			_, num := numberString(reflect.Zero(t))
+			has = num && len(rd.c.Values) > 0
+		}
+	}
+	rd.types.Store(t, has)
+	return has
+}
+
+func (rd *redactor) tagged(f reflect.StructField) bool {
+	return rd.c.StructTag != "" && f.Tag.Get(rd.c.StructTag) == "redact"
+}
+
+// redact returns a copy of v with the sensitive parts replaced. It returns
+// false if a part of v can't be redacted in place: an unexported tagged field
+// or a value which error or String method output matches the value patterns.
+func (rd *redactor) redact(v reflect.Value, depth int) (reflect.Value, bool) {
+	if !rd.walks(v.Type(), depth) {
+		return v, true
+	}
+	t := v.Type()
+	if t.Kind() != reflect.Interface && (t.Implements(errorType) || t.Implements(stringerType)) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return v, true
+		}
+		return v, !rd.matchValue(fmt.Sprint(v.Interface()))
+	}
+	if s, ok := numberString(v); ok {
+		if rd.matchValue(s) {
+			return reflect.Zero(t), true
+		}
+		return v, true
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(rd.redactString(v.String())).Convert(t), true
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, true
+		}
+		rv := reflect.ValueOf(rd.value(v.Elem().Interface(), depth+1))
+		if !rv.Type().AssignableTo(t) {
+			return v, false
+		}
+		c := reflect.New(t).Elem()
+		c.Set(rv)
+		return c, true
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, true
+		}
+		e, ok := rd.redact(v.Elem(), depth+1)
+		if !ok {
+			return v, false
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(e)
+		return p, true
+	case reflect.Slice, reflect.Array:
+		var c reflect.Value
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return v, true
+			}
+			c = reflect.MakeSlice(t, v.Len(), v.Len())
+		} else {
+			c = reflect.New(t).Elem()
+		}
+		for i := 0; i < v.Len(); i++ {
+			e, ok := rd.redact(v.Index(i), depth+1)
+			if !ok {
+				return v, false
+			}
+			c.Index(i).Set(e)
+		}
+		return c, true
+	case reflect.Map:
+		if v.IsNil() {
+			return v, true
+		}
+		c := reflect.MakeMapWithSize(t, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k, e := iter.Key(), iter.Value()
+			if k.Kind() == reflect.String && rd.matchKey(k.String()) {
+				e = rd.replaceAs(e)
+			} else {
+				var ok bool
+				if e, ok = rd.redact(e, depth+1); !ok {
+					return v, false
+				}
+			}
+			c.SetMapIndex(k, e)
+		}
+		return c, true
+	case reflect.Struct:
+		c := reflect.New(t).Elem()
+		c.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" {
+				// unexported fields can't be set
+				if rd.tagged(sf) {
+					return v, false
+				}
+				continue
+			}
+			f := c.Field(i)
+			if rd.tagged(sf) {
+				f.Set(rd.replaceAs(f))
+				continue
+			}
+			e, ok := rd.redact(f, depth+1)
+			if !ok {
+				return v, false
+			}
+			f.Set(e)
+		}
+		return c, true
+	}
+	return v, true
+}
+
+// replaceAs returns the replacement of the whole value v, of the same type:
+// the replaced text for strings and interfaces, the zero value otherwise.
+func (rd *redactor) replaceAs(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(rd.replace(v.String())).Convert(v.Type())
+	case reflect.Interface:
+		if v.IsNil() || !reflect.TypeOf("").AssignableTo(v.Type()) {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(reflect.ValueOf(rd.replaceValue(v.Interface())))
+		return c
+	}
+	return reflect.Zero(v.Type())
+}
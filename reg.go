@@ -2,35 +2,119 @@ package log15
 
 import (
 	"errors"
+	"math"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // reg is the registry of loggers.
-// It's used to share loggers among libraries
+// It's used to share loggers among libraries.
+// Logger names are hierarchical: "db.pool" is a child of "db", and top level
+// names are children of the root logger. A child inherits the handler and the level
+// from its nearest ancestor which has one set.
 var reg = map[string]Logger{}
+var regGates = map[string]*lvlGate{}
+var regLvls = map[string]Lvl{}
 var regMutex = sync.Mutex{}
 
+// lvlGate holds the effective level of a registered logger. It's shared by
+// the logger and all its children created with New.
+type lvlGate struct {
+	lvl int32
+}
+
+func newLvlGate(name string) *lvlGate {
+	g := &lvlGate{}
+	g.set(effectiveLvl(name))
+	return g
+}
+
+// noLvl is the gate value when no level is set for a logger nor its ancestors.
+const noLvl = math.MaxInt32
+
+func (g *lvlGate) enabled(lvl Lvl) bool {
+	return g == nil || lvl <= Lvl(atomic.LoadInt32(&g.lvl))
+}
+
+func (g *lvlGate) set(lvl int32) {
+	atomic.StoreInt32(&g.lvl, lvl)
+}
+
+// parentName returns the name of the parent logger. The root logger name is "".
+func parentName(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// effectiveLvl returns the level of the nearest configured ancestor of name
+// (including name itself). It must be called with regMutex held.
+func effectiveLvl(name string) int32 {
+	for {
+		if lvl, ok := regLvls[name]; ok {
+			return int32(lvl)
+		}
+		if name == "" {
+			return noLvl
+		}
+		name = parentName(name)
+	}
+}
+
 // Get returns a logger from the registry. If it doesnt' exsist it creates and registers
-// the new one.
+// the new one as a child of its parent logger (which is created as well if needed).
+// The empty name refers to the root logger.
 func Get(name string) Logger {
 	regMutex.Lock()
 	defer regMutex.Unlock()
+	return get(name)
+}
+
+func get(name string) Logger {
+	if name == "" {
+		return root
+	}
 	l, ok := reg[name]
 	if ok {
 		return l
 	}
-	l = New()
+	l = get(parentName(name)).New()
+	if ll, ok := l.(*logger); ok {
+		ll.name = name
+		ll.lvl = newLvlGate(name)
+		regGates[name] = ll.lvl
+	}
 	reg[name] = l
 	return l
 }
 
 // Set puts a new logger into registry if it's not yet there,
-// otherwise copy into the old logger. It requires that the Logger type is *logger
+// otherwise copy into the old logger. It requires that the Logger type is *logger.
+// A *logger passed for a new name isn't modified: a child of it, with the same
+// context and handler, is registered instead, so Get(name) returns that child
+// rather than l. The root logger can't be registered under another name.
+// Copying into the old logger is safe while it's being used concurrently.
 func Set(name string, l Logger) error {
 	regMutex.Lock()
 	defer regMutex.Unlock()
 	lOld, ok := reg[name]
+	if name == "" {
+		lOld, ok = root, true
+	}
 	if !ok {
+		if ll, ok := l.(*logger); ok {
+			if ll == root {
+				return errors.New("the root logger can't be registered as " + name)
+			}
+			child := ll.New().(*logger)
+			child.name = name
+			child.lvl = newLvlGate(name)
+			regGates[name] = child.lvl
+			l = child
+		}
 		reg[name] = l
 		return nil
 	}
@@ -42,6 +126,90 @@ func Set(name string, l Logger) error {
 	if !ok {
 		return errors.New("unsupported logger type to overwrite already esisting logger")
 	}
-	*llOld = *ll
+	// keep the registry identity of the old logger, so its children still inherit from it
+	llOld.ctx.Store(ll.context())
+	llOld.SetHandler(ll.GetHandler())
 	return nil
 }
+
+// InheritHandler makes the logger registered under name write to the handler
+// of its parent logger again, undoing its SetHandler calls.
+func InheritHandler(name string) {
+	regMutex.Lock()
+	defer regMutex.Unlock()
+	if name == "" {
+		return
+	}
+	l := get(name)
+	parent := get(parentName(name))
+	if pl, ok := parent.(*logger); ok {
+		l.SetHandler(pl.h)
+	} else {
+		l.SetHandler(parent.GetHandler())
+	}
+}
+
+// SetLevel sets the level of the logger registered under name and of all its descendants
+// which don't have their own level. It applies to loggers already returned by Get.
+// The empty name refers to the root logger, and so to all loggers. A trailing ".*"
+// is accepted: "db.*" is the same as "db".
+//
+// The level is checked before the record reaches the handler, so it can also be used
+// to enable more verbose levels than the root handler filter would let through,
+// as long as the root handler doesn't contain its own LvlFilterHandler.
+func SetLevel(name string, lvl Lvl) {
+	regMutex.Lock()
+	defer regMutex.Unlock()
+	regLvls[strings.TrimSuffix(name, ".*")] = lvl
+	updateGates()
+}
+
+// ClearLevel removes the level set for name, so the logger inherits the level from
+// its ancestors again.
+func ClearLevel(name string) {
+	regMutex.Lock()
+	defer regMutex.Unlock()
+	delete(regLvls, strings.TrimSuffix(name, ".*"))
+	updateGates()
+}
+
+// EffectiveLevel returns the level which applies to the logger registered under name.
+// It returns false if no level is set for the logger nor its ancestors.
+func EffectiveLevel(name string) (Lvl, bool) {
+	regMutex.Lock()
+	defer regMutex.Unlock()
+	lvl := effectiveLvl(strings.TrimSuffix(name, ".*"))
+	return Lvl(lvl), lvl != noLvl
+}
+
+// ConfiguredLevel returns the level set with SetLevel for name.
+// It returns false if the logger inherits its level.
+func ConfiguredLevel(name string) (Lvl, bool) {
+	regMutex.Lock()
+	defer regMutex.Unlock()
+	lvl, ok := regLvls[strings.TrimSuffix(name, ".*")]
+	return lvl, ok
+}
+
+// Names returns the sorted names of all registered loggers, including
+// the root logger ("").
+func Names() []string {
+	regMutex.Lock()
+	defer regMutex.Unlock()
+	names := make([]string, 0, len(reg)+1)
+	names = append(names, "")
+	for name := range reg {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// updateGates must be called with regMutex held.
+func updateGates() {
+	for name, g := range regGates {
+		g.set(effectiveLvl(name))
+	}
+}
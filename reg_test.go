@@ -0,0 +1,113 @@
+package log15
+
+import "testing"
+
+func TestRegistryHierarchy(t *testing.T) {
+	t.Parallel()
+
+	h, r := testHandler()
+	parent := Get("regtest")
+	parent.SetHandler(h)
+	child := Get("regtest.db.pool")
+	child.Info("from child")
+	if r.Msg != "from child" || r.Name != "regtest.db.pool" {
+		t.Fatalf("expected child to inherit the parent handler, got %+v", r)
+	}
+	if Get("regtest.db") == nil || Get("regtest.db.pool") != child {
+		t.Fatalf("expected registered loggers to be reused")
+	}
+}
+
+func TestRegistryLevels(t *testing.T) {
+	t.Parallel()
+
+	h, r := testHandler()
+	Get("lvltest").SetHandler(h)
+	db := Get("lvltest.db")
+	http := Get("lvltest.http").New("conn", 1)
+
+	SetLevel("lvltest", LvlWarn)
+	SetLevel("lvltest.db.*", LvlDebug)
+	defer ClearLevel("lvltest")
+
+	db.Debug("db debug")
+	if r.Msg != "db debug" {
+		t.Fatalf("expected db debug record to pass, got %q", r.Msg)
+	}
+	http.Info("http info")
+	if r.Msg != "db debug" {
+		t.Fatalf("expected http info record to be filtered")
+	}
+	if http.Enabled(LvlInfo) || !http.Enabled(LvlWarn) {
+		t.Fatalf("wrong Enabled result for http logger")
+	}
+	if lvl, ok := EffectiveLevel("lvltest.db.pool"); !ok || lvl != LvlDebug {
+		t.Fatalf("wrong effective level: %v %v", lvl, ok)
+	}
+
+	ClearLevel("lvltest.db")
+	db.Debug("db debug 2")
+	if r.Msg != "db debug" {
+		t.Fatalf("expected db to inherit warn level after clearing its level")
+	}
+	db.Error("db error")
+	if r.Msg != "db error" {
+		t.Fatalf("expected db error record to pass")
+	}
+	if _, ok := EffectiveLevel("other"); ok {
+		t.Fatalf("expected no effective level for unconfigured logger")
+	}
+}
+
+func TestRegistrySet(t *testing.T) {
+	t.Parallel()
+
+	h, r := testHandler()
+	l := New("k", 1)
+	l.SetHandler(h)
+	name, gate := l.(*logger).name, l.(*logger).lvl
+	if err := Set("settest", l); err != nil {
+		t.Fatal(err)
+	}
+	if ll := l.(*logger); ll.name != name || ll.lvl != gate {
+		t.Fatalf("expected the passed logger not to be modified")
+	}
+	Get("settest").Info("registered")
+	if r.Msg != "registered" || r.Name != "settest" || len(r.Ctx) != 2 {
+		t.Fatalf("wrong record of the registered logger: %+v", r)
+	}
+
+	rootGate := Root().(*logger).lvl
+	if err := Set("settest.root", Root()); err == nil {
+		t.Fatalf("expected an error registering the root logger")
+	}
+	if Root().(*logger).name != "" || Root().(*logger).lvl != rootGate {
+		t.Fatalf("expected the root logger not to be modified")
+	}
+}
+
+func TestRegistrySetConcurrent(t *testing.T) {
+	t.Parallel()
+
+	l := Get("setrace")
+	l.SetHandler(DiscardHandler())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			l.Info("msg")
+			l.New("k", i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		nl := New("i", i)
+		nl.SetHandler(DiscardHandler())
+		if err := Set("setrace", nl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+	if ctx := Get("setrace").(*logger).context(); len(ctx) != 2 || ctx[1] != 99 {
+		t.Fatalf("wrong context of the registered logger: %v", ctx)
+	}
+}
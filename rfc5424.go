@@ -0,0 +1,226 @@
+package log15
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SyslogFacility is the facility of RFC 5424 syslog messages.
+type SyslogFacility int
+
+// List of syslog facilities
+const (
+	FacilityKern SyslogFacility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+)
+
+// List of local use syslog facilities
+const (
+	FacilityLocal0 SyslogFacility = iota + 16
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// rfc5424Severity maps log15 levels to RFC 5424 severities.
+var rfc5424Severity = [6]int{
+	LvlCrit:  2,
+	LvlError: 3,
+	LvlWarn:  4,
+	LvlInfo:  6,
+	LvlDebug: 7,
+	LvlTrace: 7,
+}
+
+// RFC5424Config configures RFC5424Format and RFC5424Handler.
+type RFC5424Config struct {
+	// Facility of the messages. Default: FacilityUser (FacilityKern is reserved
+	// for the kernel messages).
+	Facility SyslogFacility
+	// Hostname is the HOSTNAME field. Default: os.Hostname().
+	Hostname string
+	// AppName is the APP-NAME field. Default: the program name.
+	AppName string
+	// ProcID is the PROCID field. Default: the process ID.
+	ProcID string
+	// MsgIDKey is the context key whose value is used as the MSGID field.
+	// Default: "msgid".
+	MsgIDKey string
+	// SDID is the SD-ID of the STRUCTURED-DATA element with the record context.
+	// Default: "ctx@32473" (the enterprise number reserved for documentation).
+	SDID string
+}
+
+func (c RFC5424Config) withDefaults() RFC5424Config {
+	if c.Facility == 0 {
+		c.Facility = FacilityUser
+	}
+	if c.Hostname == "" {
+		c.Hostname, _ = os.Hostname()
+	}
+	if c.AppName == "" {
+		c.AppName = filepath.Base(os.Args[0])
+	}
+	if c.ProcID == "" {
+		c.ProcID = strconv.Itoa(os.Getpid())
+	}
+	if c.MsgIDKey == "" {
+		c.MsgIDKey = "msgid"
+	}
+	if c.SDID == "" {
+		c.SDID = "ctx@32473"
+	}
+	return c
+}
+
+// RFC5424Format formats records as RFC 5424 syslog messages:
+//
+//     <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID key="value" ...] MSG
+//
+// The record context becomes the parameters of a single STRUCTURED-DATA element,
+// except the c.MsgIDKey value which is used as MSGID. Errors logged without keys
+// are added as "err" parameters and the logger name as "logger" parameter.
+// The message is not terminated with a new line; use OctetCountingFormat
+// for stream transports.
+func RFC5424Format(c RFC5424Config) Format {
+	c = c.withDefaults()
+	header := " " + rfc5424Field(c.Hostname, 255) + " " + rfc5424Field(c.AppName, 48) +
+		" " + rfc5424Field(c.ProcID, 128) + " "
+	sdID := rfc5424Name(c.SDID)
+	return FormatFunc(func(r *Record) []byte {
+		sev := 7
+		if r.Lvl >= 0 && int(r.Lvl) < len(rfc5424Severity) {
+			sev = rfc5424Severity[r.Lvl]
+		}
+		ts := "-"
+		if !r.Time.IsZero() {
+			ts = r.Time.Format("2006-01-02T15:04:05.000000Z07:00")
+		}
+		buf := &bytes.Buffer{}
+		fmt.Fprintf(buf, "<%d>1 %s", int(c.Facility)*8+sev, ts)
+		buf.WriteString(header)
+
+		msgID := "-"
+		var params []string
+		add := func(k string, v interface{}) {
+			params = append(params, rfc5424Name(k)+`="`+rfc5424Escape(fmt.Sprint(formatJSONValue(v)))+`"`)
+		}
+		if r.Name != "" {
+			add("logger", r.Name)
+		}
+		errs := walkCtx(r.Ctx, func(k string, v interface{}) {
+			if k == c.MsgIDKey {
+				msgID = rfc5424Field(fmt.Sprint(v), 32)
+			} else {
+				add(k, v)
+			}
+		})
+		if len(errs) > 0 {
+			add("err", joinErrors(errs))
+		}
+		buf.WriteString(msgID)
+		if len(params) == 0 {
+			buf.WriteString(" -")
+		} else {
+			buf.WriteString(" [" + sdID + " " + strings.Join(params, " ") + "]")
+		}
+		if r.Msg != "" {
+			buf.WriteByte(' ')
+			buf.WriteString(r.Msg)
+		}
+		return buf.Bytes()
+	})
+}
+
+// rfc5424Field returns s as a header field: printable US-ASCII of at most
+// max characters, or "-" if it's empty.
+func rfc5424Field(s string, max int) string {
+	if s == "" {
+		return "-"
+	}
+	b := []byte(s)
+	if len(b) > max {
+		b = b[:max]
+	}
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// rfc5424Name returns s as SD-NAME: at most 32 printable US-ASCII characters
+// except '=', ' ', ']' and '"'.
+func rfc5424Name(s string) string {
+	b := []byte(rfc5424Field(s, 32))
+	for i, c := range b {
+		if c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+var rfc5424Escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// rfc5424Escape escapes the PARAM-VALUE characters.
+func rfc5424Escape(s string) string {
+	return rfc5424Escaper.Replace(s)
+}
+
+// OctetCountingFormat frames records formatted with fmtr with their length,
+// as defined by RFC 6587 for syslog over TCP: "LEN MSG".
+func OctetCountingFormat(fmtr Format) Format {
+	return FormatFunc(func(r *Record) []byte {
+		b := fmtr.Format(r)
+		return append([]byte(strconv.Itoa(len(b))+" "), b...)
+	})
+}
+
+// RFC5424Handler returns a handler which sends RFC 5424 syslog messages to the
+// syslog daemon at the given address. The network may be udp, tcp, unix or
+// unixgram; on stream networks messages are framed with OctetCountingFormat.
+// Set rc.TLSConfig to use TLS over tcp (RFC 5425). The connection is managed by
+// ReconnectingNetHandler, see it for the details.
+func RFC5424Handler(network, addr string, c RFC5424Config, rc ReconnectConfig) (*ReconnectingNet, error) {
+	fmtr := RFC5424Format(c)
+	if isStreamNetwork(network) {
+		fmtr = OctetCountingFormat(fmtr)
+	}
+	return ReconnectingNetHandler(network, addr, fmtr, rc)
+}
+
+func isStreamNetwork(network string) bool {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return true
+	}
+	return false
+}
+
+func (m muster) RFC5424Handler(network, addr string, c RFC5424Config, rc ReconnectConfig) *ReconnectingNet {
+	h, err := RFC5424Handler(network, addr, c, rc)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
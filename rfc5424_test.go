@@ -0,0 +1,138 @@
+package log15
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testRFC5424Config = RFC5424Config{
+	Facility: FacilityLocal3,
+	Hostname: "web 1",
+	AppName:  "shop",
+	ProcID:   "42",
+}
+
+func TestRFC5424Format(t *testing.T) {
+	t.Parallel()
+
+	r := &Record{
+		Time: time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC),
+		Lvl:  LvlWarn,
+		Msg:  "payment failed",
+		Ctx:  []interface{}{"msgid", "PAY01", "user", "bob", "note", `a "b" ]c\`, errors.New("declined")},
+		Name: "shop.pay",
+	}
+	expected := `<156>1 2020-01-02T03:04:05.000006Z web_1 shop 42 PAY01 ` +
+		`[ctx@32473 logger="shop.pay" user="bob" note="a \"b\" \]c\\" err="declined"] payment failed`
+	if out := string(RFC5424Format(testRFC5424Config).Format(r)); out != expected {
+		t.Fatalf("wrong message:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	r = &Record{Lvl: LvlCrit}
+	expected = `<154>1 - web_1 shop 42 - -`
+	if out := string(RFC5424Format(testRFC5424Config).Format(r)); out != expected {
+		t.Fatalf("wrong message:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+// readOctetCounted reads RFC 6587 octet counted messages from the stream.
+func readOctetCounted(t *testing.T, ln net.Listener) <-chan string {
+	msgs := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		for {
+			l, err := br.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(l[:len(l)-1])
+			if err != nil {
+				t.Errorf("wrong frame length %q", l)
+				return
+			}
+			b := make([]byte, n)
+			if _, err := io.ReadFull(br, b); err != nil {
+				return
+			}
+			msgs <- string(b)
+		}
+	}()
+	return msgs
+}
+
+func TestRFC5424HandlerStream(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	msgs := readOctetCounted(t, ln)
+
+	h, err := RFC5424Handler("tcp", ln.Addr().String(), testRFC5424Config, ReconnectConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.Log(&Record{Lvl: LvlInfo, Msg: "one\ntwo"})
+	h.Log(&Record{Lvl: LvlInfo, Msg: "three", Ctx: []interface{}{"k", 1}})
+	expectLines(t, msgs, "<158>1 - web_1 shop 42 - - one\ntwo", `<158>1 - web_1 shop 42 - [ctx@32473 k="1"] three`)
+}
+
+func TestRFC5424HandlerDatagram(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "log15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "log.sock")
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	unix, err := net.ListenPacket("unixgram", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close()
+
+	for _, srv := range []net.PacketConn{udp, unix} {
+		addr := srv.LocalAddr()
+		h, err := RFC5424Handler(addr.Network(), addr.String(), testRFC5424Config, ReconnectConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Log(&Record{Lvl: LvlError, Msg: "hello"})
+		if err := h.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 1024)
+		srv.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := srv.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out := string(buf[:n]); out != "<155>1 - web_1 shop 42 - - hello" {
+			t.Fatalf("%s: wrong message %q", addr.Network(), out)
+		}
+		h.Close()
+	}
+}
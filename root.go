@@ -1,6 +1,7 @@
 package log15
 
 import (
+	"context"
 	"os"
 
 	"github.com/mattn/go-colorable"
@@ -25,7 +26,9 @@ func init() {
 			TerminalFormat{true, termTimeFormat, ""})
 	}
 
-	root = &logger{[]interface{}{}, new(swapHandler)}
+	root = &logger{h: new(swapHandler), lvl: newLvlGate("")}
+	root.ctx.Store([]interface{}{})
+	regGates[""] = root.lvl
 	root.SetHandler(LvlFilterHandler(LvlError, StdoutHandler))
 }
 
@@ -40,42 +43,100 @@ func Root() Logger {
 	return root
 }
 
+// Enabled is a convenient alias for Root().Enabled
+func Enabled(lvl Lvl) bool {
+	return root.Enabled(lvl)
+}
+
+// Shutdown closes the handler tree of the root logger, flushing all pending
+// records and releasing files and sockets. All loggers created with New or Get
+// share that tree unless they were given their own handler.
+// The root handler is replaced with DiscardHandler before closing, so records
+// logged after Shutdown are dropped. Shutdown returns ctx.Err() if the handlers
+// don't finish closing before ctx is done. They keep closing in the background
+// then, so the writers and connections used by the handlers must not be torn
+// down by the caller until the program exits.
+func Shutdown(ctx context.Context) error {
+	h := root.GetHandler()
+	root.SetHandler(DiscardHandler())
+	done := make(chan error, 1)
+	go func() {
+		done <- TryClose(h)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // The following functions bypass the exported logger methods (logger.Debug,
 // etc.) to keep the call depth the same for all paths to logger.write so
 // runtime.Caller(2) always refers to the call site in client code.
 
 // Trace is a convenient alias for Root().Debug
 func Trace(msg string, ctx ...interface{}) {
-	root.write(msg, LvlTrace, ctx)
+	root.write(nil, msg, LvlTrace, ctx)
 }
 
 // Debug is a convenient alias for Root().Debug
 func Debug(msg string, ctx ...interface{}) {
-	root.write(msg, LvlDebug, ctx)
+	root.write(nil, msg, LvlDebug, ctx)
 }
 
 // Info is a convenient alias for Root().Info
 func Info(msg string, ctx ...interface{}) {
-	root.write(msg, LvlInfo, ctx)
+	root.write(nil, msg, LvlInfo, ctx)
 }
 
 // Warn is a convenient alias for Root().Warn
 func Warn(msg string, ctx ...interface{}) {
-	root.write(msg, LvlWarn, ctx)
+	root.write(nil, msg, LvlWarn, ctx)
 }
 
 // Error is a convenient alias for Root().Error
 func Error(msg string, ctx ...interface{}) {
-	root.write(msg, LvlError, ctx)
+	root.write(nil, msg, LvlError, ctx)
 }
 
 // Crit is a convenient alias for Root().Crit
 func Crit(msg string, ctx ...interface{}) {
-	root.write(msg, LvlCrit, ctx)
+	root.write(nil, msg, LvlCrit, ctx)
 }
 
 // Fatal is a convenient alias for Root().Fatal
 func Fatal(msg string, ctx ...interface{}) {
-	root.write(msg, LvlCrit, ctx)
+	root.write(nil, msg, LvlCrit, ctx)
 	panic("FATAL. " + msg)
 }
+
+// TraceCtx is a convenient alias for Root().TraceCtx
+func TraceCtx(c context.Context, msg string, ctx ...interface{}) {
+	root.write(c, msg, LvlTrace, ctx)
+}
+
+// DebugCtx is a convenient alias for Root().DebugCtx
+func DebugCtx(c context.Context, msg string, ctx ...interface{}) {
+	root.write(c, msg, LvlDebug, ctx)
+}
+
+// InfoCtx is a convenient alias for Root().InfoCtx
+func InfoCtx(c context.Context, msg string, ctx ...interface{}) {
+	root.write(c, msg, LvlInfo, ctx)
+}
+
+// WarnCtx is a convenient alias for Root().WarnCtx
+func WarnCtx(c context.Context, msg string, ctx ...interface{}) {
+	root.write(c, msg, LvlWarn, ctx)
+}
+
+// ErrorCtx is a convenient alias for Root().ErrorCtx
+func ErrorCtx(c context.Context, msg string, ctx ...interface{}) {
+	root.write(c, msg, LvlError, ctx)
+}
+
+// CritCtx is a convenient alias for Root().CritCtx
+func CritCtx(c context.Context, msg string, ctx ...interface{}) {
+	root.write(c, msg, LvlCrit, ctx)
+}
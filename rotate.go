@@ -0,0 +1,336 @@
+package log15
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotationPeriod defines the wall clock schedule of RotatingFileHandler.
+type RotationPeriod int
+
+// List of supported rotation periods
+const (
+	RotateNever RotationPeriod = iota
+	RotateHourly
+	RotateDaily
+)
+
+// start returns the beginning of the period which contains t.
+func (p RotationPeriod) start(t time.Time) time.Time {
+	switch p {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case RotateDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// RotatingFileConfig configures RotatingFileHandler.
+type RotatingFileConfig struct {
+	// MaxSize is the maximum size of the log file in bytes. The file is rotated
+	// before a write which would exceed it. Zero disables size based rotation.
+	MaxSize int64
+	// Period rotates the file when the wall clock enters a new hour or day.
+	Period RotationPeriod
+	// MaxBackups is the number of rotated files to keep. Zero keeps all of them.
+	MaxBackups int
+	// Compress gzips rotated files in the background.
+	Compress bool
+	// ReopenOnSIGHUP reopens the file when the process receives SIGHUP. Use it when
+	// an external tool (eg: logrotate) moves the file.
+	ReopenOnSIGHUP bool
+	// OnError is called with errors of the background compression and clean up.
+	OnError func(err error)
+}
+
+// RotatingFile is the Handler returned by RotatingFileHandler.
+type RotatingFile struct {
+	path string
+	fmtr Format
+	c    RotatingFileConfig
+	now  func() time.Time
+	lazy Handler
+
+	mu     sync.Mutex // guards the fields below
+	f      *os.File
+	size   int64
+	period time.Time // start of the rotation period of the current file
+	closed bool
+
+	millMu sync.Mutex // serializes compression and clean up of backups
+	mills  sync.WaitGroup
+	sighup chan os.Signal
+}
+
+// RotatingFileHandler returns a handler which writes log records to the given file
+// using the given format, like FileHandler. The file is rotated when it grows
+// over c.MaxSize or when a new c.Period starts. Rotated files are renamed to
+// `path.<timestamp>` (with `.gz` suffix when compressed) and only the c.MaxBackups
+// most recent of them are kept.
+func RotatingFileHandler(path string, fmtr Format, c RotatingFileConfig) (*RotatingFile, error) {
+	h := &RotatingFile{path: path, fmtr: fmtr, c: c, now: time.Now}
+	h.lazy = LazyHandler(FuncHandler(h.write))
+	if err := h.open(); err != nil {
+		return nil, err
+	}
+	if c.ReopenOnSIGHUP {
+		h.sighup = make(chan os.Signal, 1)
+		signal.Notify(h.sighup, syscall.SIGHUP)
+		go func() {
+			for range h.sighup {
+				h.Reopen()
+			}
+		}()
+	}
+	return h, nil
+}
+
+// open opens the log file. It must be called with h.mu held.
+func (h *RotatingFile) open() error {
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	h.f = f
+	h.size = info.Size()
+	if h.size > 0 {
+		h.period = h.c.Period.start(info.ModTime())
+	} else {
+		h.period = h.c.Period.start(h.now())
+	}
+	return nil
+}
+
+// Log implements Handler interface.
+func (h *RotatingFile) Log(r *Record) error {
+	return h.lazy.Log(r)
+}
+
+func (h *RotatingFile) write(r *Record) error {
+	b := h.fmtr.Format(r)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrHandlerClosed
+	}
+	if h.needsRotation(len(b)) {
+		if err := h.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := h.f.Write(b)
+	h.size += int64(n)
+	return err
+}
+
+func (h *RotatingFile) needsRotation(n int) bool {
+	if h.c.MaxSize > 0 && h.size > 0 && h.size+int64(n) > h.c.MaxSize {
+		return true
+	}
+	return h.c.Period != RotateNever && !h.c.Period.start(h.now()).Equal(h.period)
+}
+
+// Rotate rotates the log file immediately.
+func (h *RotatingFile) Rotate() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrHandlerClosed
+	}
+	return h.rotate()
+}
+
+// rotate must be called with h.mu held.
+func (h *RotatingFile) rotate() error {
+	if err := h.f.Close(); err != nil {
+		return err
+	}
+	backup := h.backupName()
+	if err := os.Rename(h.path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := h.open(); err != nil {
+		return err
+	}
+	h.mills.Add(1)
+	go h.mill(backup)
+	return nil
+}
+
+// backupName returns an unused name for the rotated file.
+func (h *RotatingFile) backupName() string {
+	base := h.path + "." + h.now().Format(backupTimeFormat)
+	name := base
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return name
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// Reopen closes and reopens the log file without rotating it. It's called on SIGHUP
+// when RotatingFileConfig.ReopenOnSIGHUP is set.
+func (h *RotatingFile) Reopen() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrHandlerClosed
+	}
+	if err := h.f.Close(); err != nil {
+		return err
+	}
+	return h.open()
+}
+
+// Flush commits the file content to stable storage.
+func (h *RotatingFile) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	return h.f.Sync()
+}
+
+// Close closes the file and waits for the background compression of rotated files.
+func (h *RotatingFile) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	err := h.f.Close()
+	h.mu.Unlock()
+	if h.sighup != nil {
+		signal.Stop(h.sighup)
+		close(h.sighup)
+	}
+	h.mills.Wait()
+	return err
+}
+
+// mill compresses the rotated file and removes old backups.
+func (h *RotatingFile) mill(backup string) {
+	defer h.mills.Done()
+	h.millMu.Lock()
+	defer h.millMu.Unlock()
+	if h.c.Compress {
+		h.reportErr(compressFile(backup))
+	}
+	if h.c.MaxBackups > 0 {
+		h.reportErr(h.prune())
+	}
+}
+
+func (h *RotatingFile) reportErr(err error) {
+	if err != nil && h.c.OnError != nil {
+		h.c.OnError(err)
+	}
+}
+
+// backups returns the rotated files sorted from the oldest.
+func (h *RotatingFile) backups() ([]string, error) {
+	dir, prefix := filepath.Split(h.path)
+	if dir == "" {
+		dir = "."
+	}
+	prefix += "."
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(name[len(prefix):], ".gz")
+		if len(ts) < len(backupTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, ts[:len(backupTimeFormat)]); err != nil {
+			continue
+		}
+		names = append(names, filepath.Join(dir, name))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (h *RotatingFile) prune() error {
+	names, err := h.backups()
+	if err != nil {
+		return err
+	}
+	for len(names) > h.c.MaxBackups {
+		if rerr := os.Remove(names[0]); rerr != nil && err == nil {
+			err = rerr
+		}
+		names = names[1:]
+	}
+	return err
+}
+
+// compressFile gzips the file at path and removes it.
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(path + ".gz")
+		}
+	}()
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
+
+func (m muster) RotatingFileHandler(path string, fmtr Format, c RotatingFileConfig) *RotatingFile {
+	h, err := RotatingFileHandler(path, fmtr, c)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
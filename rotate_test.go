@@ -0,0 +1,124 @@
+package log15
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rotatingTestFile(t *testing.T, c RotatingFileConfig) (*RotatingFile, string, func()) {
+	dir, err := ioutil.TempDir("", "log15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "app.log")
+	h, err := RotatingFileHandler(path, FormatFunc(func(r *Record) []byte {
+		return []byte(r.Msg + "\n")
+	}), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, path, func() {
+		h.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRotatingFileMaxSize(t *testing.T) {
+	t.Parallel()
+
+	h, path, cleanup := rotatingTestFile(t, RotatingFileConfig{MaxSize: 10, MaxBackups: 2})
+	defer cleanup()
+	for _, msg := range []string{"aaaa", "bbbb", "cccc", "dddd", "eeee"} {
+		if err := h.Log(&Record{Msg: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "eeee\n" {
+		t.Fatalf("wrong content of the current file: %q", b)
+	}
+	backups, err := h.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	b, _ = ioutil.ReadFile(backups[1])
+	if string(b) != "cccc\ndddd\n" {
+		t.Fatalf("wrong content of the last backup: %q", b)
+	}
+}
+
+func TestRotatingFilePeriodCompress(t *testing.T) {
+	t.Parallel()
+
+	h, path, cleanup := rotatingTestFile(t, RotatingFileConfig{Period: RotateHourly, Compress: true})
+	defer cleanup()
+	now := time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+	h.period = RotateHourly.start(now)
+
+	h.Log(&Record{Msg: "first"})
+	now = now.Add(40 * time.Minute)
+	h.Log(&Record{Msg: "second"})
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := h.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".gz") {
+		t.Fatalf("expected one compressed backup, got %v", backups)
+	}
+	f, err := os.Open(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(gz)
+	if string(b) != "first\n" {
+		t.Fatalf("wrong content of the backup: %q", b)
+	}
+	b, _ = ioutil.ReadFile(path)
+	if string(b) != "second\n" {
+		t.Fatalf("wrong content of the current file: %q", b)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	t.Parallel()
+
+	h, path, cleanup := rotatingTestFile(t, RotatingFileConfig{})
+	defer cleanup()
+	h.Log(&Record{Msg: "old"})
+	if err := os.Rename(path, path+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	h.Log(&Record{Msg: "new"})
+	b, _ := ioutil.ReadFile(path)
+	if string(b) != "new\n" {
+		t.Fatalf("expected a new file after reopen, got %q", b)
+	}
+}
@@ -0,0 +1,186 @@
+package log15
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SamplingConfig configures SamplingHandler.
+type SamplingConfig struct {
+	// Window is the time window of the First / Thereafter counters. Default: 1s.
+	Window time.Duration
+	// First is the number of records with the same level, logger name and message
+	// passed in each window. Zero disables the counter based sampling.
+	First int
+	// Thereafter passes every Thereafter-th record after the First ones.
+	// Zero drops all of them.
+	Thereafter int
+	// Rates maps levels to the probability (0 .. 1) of passing a record.
+	// Levels which are not in the map are not sampled randomly.
+	Rates map[Lvl]float64
+	// SummaryInterval is the interval of summary records which report the number
+	// of records sampled away per message. Zero disables them.
+	SummaryInterval time.Duration
+}
+
+// SamplingHandler returns a handler which passes only a sample of Debug, Info,
+// Warn and Trace records to h. Error and Crit records always pass.
+// A record passes when it's one of the c.First records of its message in the
+// current window (or every c.Thereafter-th after them) and when it passes
+// the random sampling with the c.Rates probability of its level.
+// Each of the two samplings is applied only when it's configured.
+//
+// Every c.SummaryInterval, and on Close, the handler logs a record for each sampled
+// message with the number of records dropped since the previous summary:
+//
+//     lvl=dbug msg="records sampled away" sampled_msg="cache miss" dropped=1523
+//
+// Close stops the summaries and closes h.
+func SamplingHandler(c SamplingConfig, h Handler) *Sampling {
+	if c.Window <= 0 {
+		c.Window = time.Second
+	}
+	s := &Sampling{
+		c:       c,
+		h:       h,
+		now:     time.Now,
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		counts:  make(map[sampleKey]int),
+		dropped: make(map[sampleKey]int),
+		done:    make(chan struct{}),
+	}
+	if c.SummaryInterval > 0 {
+		s.wg.Add(1)
+		go s.loop()
+	}
+	return s
+}
+
+// Sampling is the Handler returned by SamplingHandler.
+type Sampling struct {
+	c    SamplingConfig
+	h    Handler
+	now  func() time.Time
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex // guards the fields below
+	rnd     *rand.Rand
+	window  time.Time // start of the current window
+	counts  map[sampleKey]int
+	dropped map[sampleKey]int
+	closed  bool
+}
+
+type sampleKey struct {
+	lvl  Lvl
+	name string
+	msg  string
+}
+
+// Log implements Handler interface.
+func (s *Sampling) Log(r *Record) error {
+	if r.Lvl <= LvlError || s.sample(r) {
+		return s.h.Log(r)
+	}
+	return nil
+}
+
+func (s *Sampling) sample(r *Record) bool {
+	k := sampleKey{r.Lvl, r.Name, r.Msg}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pass := true
+	if s.c.First > 0 {
+		if now := s.now(); now.Sub(s.window) >= s.c.Window {
+			s.window = now
+			s.counts = make(map[sampleKey]int)
+		}
+		n := s.counts[k] + 1
+		s.counts[k] = n
+		pass = n <= s.c.First || s.c.Thereafter > 0 && (n-s.c.First)%s.c.Thereafter == 0
+	}
+	if rate, ok := s.c.Rates[r.Lvl]; pass && ok {
+		pass = s.rnd.Float64() < rate
+	}
+	if !pass && s.c.SummaryInterval > 0 {
+		s.dropped[k]++
+	}
+	return pass
+}
+
+func (s *Sampling) loop() {
+	defer s.wg.Done()
+	t := time.NewTicker(s.c.SummaryInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.summary()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// summary logs the number of records dropped since the previous summary.
+func (s *Sampling) summary() {
+	s.mu.Lock()
+	dropped := s.dropped
+	s.dropped = make(map[sampleKey]int)
+	s.mu.Unlock()
+
+	keys := make([]sampleKey, 0, len(dropped))
+	for k := range dropped {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].lvl != keys[j].lvl {
+			return keys[i].lvl < keys[j].lvl
+		}
+		return keys[i].name+keys[i].msg < keys[j].name+keys[j].msg
+	})
+	for _, k := range keys {
+		s.h.Log(&Record{
+			Time: s.now(),
+			Lvl:  k.lvl,
+			Msg:  "records sampled away",
+			Ctx:  []interface{}{"sampled_msg", k.msg, "dropped", dropped[k]},
+			KeyNames: RecordKeyNames{
+				Time: timeKey,
+				Msg:  msgKey,
+				Lvl:  lvlKey,
+			},
+			Name: k.name,
+		})
+	}
+}
+
+// Enabled implements LvlChecker interface.
+func (s *Sampling) Enabled(lvl Lvl) bool {
+	return LvlEnabled(s.h, lvl)
+}
+
+// Flush flushes the wrapped handler.
+func (s *Sampling) Flush() error {
+	return TryFlush(s.h)
+}
+
+// Close logs the last summary, stops the summary goroutine and closes the wrapped handler.
+func (s *Sampling) Close() error {
+	s.mu.Lock()
+	closed := s.closed
+	s.closed = true
+	s.mu.Unlock()
+	if closed {
+		return nil
+	}
+	if s.c.SummaryInterval > 0 {
+		close(s.done)
+		s.wg.Wait()
+		s.summary()
+	}
+	return TryClose(s.h)
+}
@@ -0,0 +1,80 @@
+package log15
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSamplingFirstThereafter(t *testing.T) {
+	t.Parallel()
+
+	var msgs []string
+	h := FuncHandler(func(r *Record) error {
+		msgs = append(msgs, r.Msg)
+		return nil
+	})
+	now := time.Unix(1000, 0)
+	s := SamplingHandler(SamplingConfig{Window: time.Minute, First: 2, Thereafter: 3}, h)
+	s.now = func() time.Time { return now }
+	l := New()
+	l.SetHandler(s)
+
+	for i := 0; i < 9; i++ {
+		l.Info("a")
+		l.Debug("b")
+		l.Error("c")
+	}
+	// a: 1, 2, 5, 8; b: the same; c: all of them
+	count := map[string]int{}
+	for _, m := range msgs {
+		count[m]++
+	}
+	if count["a"] != 4 || count["b"] != 4 || count["c"] != 9 {
+		t.Fatalf("wrong sampled counts: %v", count)
+	}
+
+	now = now.Add(time.Minute)
+	msgs = nil
+	l.Info("a")
+	if len(msgs) != 1 {
+		t.Fatalf("expected the counter reset in a new window")
+	}
+}
+
+func TestSamplingRates(t *testing.T) {
+	t.Parallel()
+
+	n := 0
+	h := FuncHandler(func(r *Record) error {
+		n++
+		return nil
+	})
+	l := New()
+	l.SetHandler(SamplingHandler(SamplingConfig{Rates: map[Lvl]float64{LvlDebug: 0, LvlInfo: 1, LvlCrit: 0}}, h))
+	for i := 0; i < 10; i++ {
+		l.Debug("x")
+		l.Info("y")
+		l.Crit("z")
+	}
+	if n != 20 {
+		t.Fatalf("expected 20 records, got %d", n)
+	}
+}
+
+func TestSamplingSummary(t *testing.T) {
+	t.Parallel()
+
+	h, r := testHandler()
+	s := SamplingHandler(SamplingConfig{First: 1, SummaryInterval: time.Hour}, h)
+	l := New()
+	l.SetHandler(s)
+	for i := 0; i < 5; i++ {
+		l.Info("hello")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Msg != "records sampled away" || r.Lvl != LvlInfo || r.Ctx[1] != "hello" || r.Ctx[3] != 4 {
+		t.Fatalf("wrong summary record: %s %v", r.Msg, r.Ctx)
+	}
+}
@@ -0,0 +1,218 @@
+//go:build go1.21
+// +build go1.21
+
+package log15
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/go-stack/stack"
+)
+
+// slogLevels maps log15 levels to slog levels. log15 LvlTrace and LvlCrit
+// don't have slog counterparts, so they are placed one step outside of
+// the slog Debug .. Error range.
+var slogLevels = [6]slog.Level{
+	LvlCrit:  slog.LevelError + 4,
+	LvlError: slog.LevelError,
+	LvlWarn:  slog.LevelWarn,
+	LvlInfo:  slog.LevelInfo,
+	LvlDebug: slog.LevelDebug,
+	LvlTrace: slog.LevelDebug - 4,
+}
+
+func toSlogLevel(lvl Lvl) slog.Level {
+	if lvl < LvlCrit {
+		return slogLevels[LvlCrit]
+	}
+	if lvl > LvlTrace {
+		return slogLevels[LvlTrace]
+	}
+	return slogLevels[lvl]
+}
+
+func fromSlogLevel(l slog.Level) Lvl {
+	switch {
+	case l > slog.LevelError:
+		return LvlCrit
+	case l > slog.LevelWarn:
+		return LvlError
+	case l > slog.LevelInfo:
+		return LvlWarn
+	case l > slog.LevelDebug:
+		return LvlInfo
+	case l > slog.LevelDebug-4:
+		return LvlDebug
+	}
+	return LvlTrace
+}
+
+// NewSlogHandler returns a slog.Handler which forwards all records to the given
+// log15 Handler. slog levels are mapped to the nearest log15 levels, attributes
+// become key/value pairs of the record context and attribute groups are flattened
+// into dotted keys (eg: "req.method"). The top level "logger" attribute is the
+// record Name, as set by SlogHandler. The record Call is the slog call site
+// when the record is handled in the goroutine which logged it.
+func NewSlogHandler(h Handler) slog.Handler {
+	return &slogBridge{h: h}
+}
+
+type slogBridge struct {
+	h      Handler
+	prefix string // group prefix of the following attributes, with the trailing dot
+	ctx    []interface{}
+	name   string
+}
+
+func (b *slogBridge) Enabled(c context.Context, l slog.Level) bool {
+	return LvlEnabled(b.h, fromSlogLevel(l))
+}
+
+func (b *slogBridge) Handle(c context.Context, sr slog.Record) error {
+	ctx := make([]interface{}, len(b.ctx), len(b.ctx)+2*sr.NumAttrs())
+	copy(ctx, b.ctx)
+	name := b.name
+	sr.Attrs(func(a slog.Attr) bool {
+		if !b.loggerAttr(a, &name) {
+			ctx = appendSlogAttr(ctx, b.prefix, a)
+		}
+		return true
+	})
+	t := sr.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return b.h.Log(&Record{
+		Time: t,
+		Lvl:  fromSlogLevel(sr.Level),
+		Msg:  sr.Message,
+		Ctx:  ctx,
+		Call: slogCall(sr.PC),
+		Name: name,
+		KeyNames: RecordKeyNames{
+			Time: timeKey,
+			Msg:  msgKey,
+			Lvl:  lvlKey,
+		},
+		Context: c,
+	})
+}
+
+func (b *slogBridge) WithAttrs(attrs []slog.Attr) slog.Handler {
+	ctx := make([]interface{}, len(b.ctx), len(b.ctx)+2*len(attrs))
+	copy(ctx, b.ctx)
+	name := b.name
+	for _, a := range attrs {
+		if !b.loggerAttr(a, &name) {
+			ctx = appendSlogAttr(ctx, b.prefix, a)
+		}
+	}
+	return &slogBridge{h: b.h, prefix: b.prefix, ctx: ctx, name: name}
+}
+
+func (b *slogBridge) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return b
+	}
+	return &slogBridge{h: b.h, prefix: b.prefix + name + ".", ctx: b.ctx, name: b.name}
+}
+
+// loggerAttr sets name to the value of the top level "logger" attribute and
+// reports whether a is that attribute.
+func (b *slogBridge) loggerAttr(a slog.Attr, name *string) bool {
+	if b.prefix != "" || a.Key != slogLoggerKey || a.Value.Kind() != slog.KindString {
+		return false
+	}
+	*name = a.Value.String()
+	return true
+}
+
+// slogLoggerKey is the attribute key of the record Name.
+const slogLoggerKey = "logger"
+
+// slogCall returns the call at pc, searching the stack of the current goroutine.
+// It returns the zero Call if pc isn't there, eg: when the record was passed
+// to another goroutine.
+func slogCall(pc uintptr) stack.Call {
+	if pc == 0 {
+		return stack.Call{}
+	}
+	f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	for skip := 2; ; skip++ {
+		c := stack.Caller(skip)
+		if c.Frame().PC == 0 {
+			return stack.Call{}
+		}
+		if c.Frame().PC == f.PC {
+			return c
+		}
+	}
+}
+
+func appendSlogAttr(ctx []interface{}, prefix string, a slog.Attr) []interface{} {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			ctx = appendSlogAttr(ctx, prefix, ga)
+		}
+		return ctx
+	}
+	if a.Equal(slog.Attr{}) {
+		return ctx
+	}
+	return append(ctx, prefix+a.Key, v.Any())
+}
+
+// SlogHandler returns a Handler which writes all records into the given slog.Handler.
+// Context key/value pairs become slog attributes. Values logged without keys
+// (errors, Spew and Alone values) get the "err", "spew" or their title as keys.
+// The record Name is the "logger" attribute and the record Call the source
+// of the slog record.
+func SlogHandler(h slog.Handler) Handler {
+	return LazyHandler(slogHandler{h})
+}
+
+type slogHandler struct {
+	h slog.Handler
+}
+
+func (h slogHandler) context(r *Record) context.Context {
+	if r.Context != nil {
+		return r.Context
+	}
+	return context.Background()
+}
+
+func (h slogHandler) Enabled(lvl Lvl) bool {
+	return h.h.Enabled(context.Background(), toSlogLevel(lvl))
+}
+
+func (h slogHandler) Log(r *Record) error {
+	c := h.context(r)
+	lvl := toSlogLevel(r.Lvl)
+	if !h.h.Enabled(c, lvl) {
+		return nil
+	}
+	// slog expects the return address, as runtime.Callers reports it
+	var pc uintptr
+	if f := r.Call.Frame(); f.PC != 0 {
+		pc = f.PC + 1
+	}
+	sr := slog.NewRecord(r.Time, lvl, r.Msg, pc)
+	if r.Name != "" {
+		sr.AddAttrs(slog.String(slogLoggerKey, r.Name))
+	}
+	errs := walkCtx(r.Ctx, func(k string, v interface{}) {
+		sr.AddAttrs(slog.Any(k, v))
+	})
+	if len(errs) > 0 {
+		sr.AddAttrs(slog.String("err", joinErrors(errs)))
+	}
+	return h.h.Handle(c, sr)
+}
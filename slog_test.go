@@ -0,0 +1,116 @@
+//go:build go1.21
+// +build go1.21
+
+package log15
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNewSlogHandler(t *testing.T) {
+	t.Parallel()
+
+	h, r := testHandler()
+	sl := slog.New(NewSlogHandler(LvlFilterHandler(LvlInfo, h)))
+	sl = sl.With("app", "test").WithGroup("req")
+	sl.Warn("slow request", "method", "GET", slog.Group("user", "id", 7))
+
+	if r.Msg != "slow request" || r.Lvl != LvlWarn {
+		t.Fatalf("wrong record: %+v", r)
+	}
+	expected := []interface{}{"app", "test", "req.method", "GET", "req.user.id", int64(7)}
+	if len(r.Ctx) != len(expected) {
+		t.Fatalf("got ctx %v, expected %v", r.Ctx, expected)
+	}
+	for i := range expected {
+		if r.Ctx[i] != expected[i] {
+			t.Fatalf("got ctx %v, expected %v", r.Ctx, expected)
+		}
+	}
+	if sl.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatalf("expected debug level to be disabled by the log15 filter")
+	}
+	for l, expected := range map[slog.Level]Lvl{
+		slog.LevelDebug - 8: LvlTrace, slog.LevelDebug: LvlDebug, slog.LevelInfo: LvlInfo,
+		slog.LevelWarn: LvlWarn, slog.LevelError: LvlError, slog.LevelError + 4: LvlCrit,
+	} {
+		if got := fromSlogLevel(l); got != expected {
+			t.Fatalf("slog level %v mapped to %v, expected %v", l, got, expected)
+		}
+	}
+}
+
+func TestNewSlogHandlerCallAndName(t *testing.T) {
+	t.Parallel()
+
+	h, r := testHandler()
+	sl := slog.New(NewSlogHandler(h)).With("logger", "db.pool")
+	_, file, line, _ := runtime.Caller(0)
+	sl.Info("connected")
+
+	if f := r.Call.Frame(); f.File != file || f.Line != line+1 {
+		t.Fatalf("wrong call %s:%d, expected %s:%d", f.File, f.Line, file, line+1)
+	}
+	if r.Name != "db.pool" || len(r.Ctx) != 0 {
+		t.Fatalf("wrong record name %q and ctx %v", r.Name, r.Ctx)
+	}
+	// nested "logger" attributes are context
+	sl.WithGroup("req").Info("query", "logger", "x")
+	if r.Name != "db.pool" || len(r.Ctx) != 2 || r.Ctx[0] != "req.logger" {
+		t.Fatalf("wrong record name %q and ctx %v", r.Name, r.Ctx)
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := New("app", "test")
+	l.SetHandler(SlogHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	l.Debug("hidden")
+	if l.Enabled(LvlDebug) {
+		t.Fatalf("expected debug level to be disabled by the slog handler")
+	}
+	l.Error("failed", "x", Lazy{func() int { return 3 }}, errors.New("boom"))
+
+	out := buf.String()
+	for _, s := range []string{"level=ERROR", "msg=failed", "app=test", "x=3", "err=boom"} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("expected %q in the output: %s", s, out)
+		}
+	}
+	if strings.Contains(out, "hidden") {
+		t.Fatalf("expected debug record to be filtered: %s", out)
+	}
+}
+
+func TestSlogHandlerSourceAndName(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := Get("slogtest.db")
+	l.SetHandler(SlogHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{AddSource: true})))
+	_, file, line, _ := runtime.Caller(0)
+	l.Info("connected")
+
+	var out struct {
+		Logger string
+		Source struct {
+			File string
+			Line int
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Logger != "slogtest.db" || out.Source.File != file || out.Source.Line != line+1 {
+		t.Fatalf("wrong logger and source: %s", buf.String())
+	}
+}
@@ -0,0 +1,68 @@
+package log15
+
+import "encoding/binary"
+
+// snappyEncode compresses src in the Snappy block format, required by the Loki
+// protobuf push API. It's a simple greedy compressor: it finds 4 byte matches
+// with a hash table and emits them as copies with 2 byte offsets.
+func snappyEncode(src []byte) []byte {
+	dst := make([]byte, binary.MaxVarintLen32, binary.MaxVarintLen32+len(src)+len(src)/6+8)
+	dst = dst[:binary.PutUvarint(dst, uint64(len(src)))]
+	const (
+		tableBits = 14
+		minMatch  = 4
+		maxOffset = 1<<16 - 1
+	)
+	var table [1 << tableBits]int32 // positions+1 of the last 4 byte sequences
+	hash := func(i int) uint32 {
+		return binary.LittleEndian.Uint32(src[i:]) * 0x1e35a7bd >> (32 - tableBits)
+	}
+	lit := 0 // start of the pending literal
+	for i := 0; i+minMatch <= len(src); {
+		h := hash(i)
+		cand := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if cand < 0 || i-cand > maxOffset ||
+			binary.LittleEndian.Uint32(src[cand:]) != binary.LittleEndian.Uint32(src[i:]) {
+			i++
+			continue
+		}
+		dst = snappyLiteral(dst, src[lit:i])
+		n := minMatch
+		for i+n < len(src) && src[cand+n] == src[i+n] {
+			n++
+		}
+		for m := n; m > 0; {
+			// copies with 2 byte offsets are 1 to 64 bytes long
+			k := m
+			if k > 64 {
+				k = 64
+			}
+			off := i - cand
+			dst = append(dst, byte(k-1)<<2|2, byte(off), byte(off>>8))
+			m -= k
+		}
+		i += n
+		lit = i
+	}
+	return snappyLiteral(dst, src[lit:])
+}
+
+func snappyLiteral(dst, lit []byte) []byte {
+	n := len(lit) - 1
+	switch {
+	case n < 0:
+		return dst
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
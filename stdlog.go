@@ -0,0 +1,164 @@
+package log15
+
+import (
+	"bytes"
+	"log"
+	"strings"
+
+	"github.com/go-stack/stack"
+)
+
+// stdLogMsgPrefix is log.Lmsgprefix, which was added in go1.14.
+const stdLogMsgPrefix = 64
+
+// RedirectStdLog redirects the output of the standard library log package into l.
+// All records are logged with the given level. It is the equivalent of
+// RedirectStdLogEx(l, lvl, false).
+func RedirectStdLog(l Logger, lvl Lvl) (undo func()) {
+	return RedirectStdLogEx(l, lvl, false)
+}
+
+// RedirectStdLogEx redirects the output of the standard library log package into l.
+// The prefix, date and time added by the log package are stripped and the file:line
+// (printed with log.Lshortfile or log.Llongfile) is added to the record context
+// as CallerCtx. If inferLvl is true, a leading level token of the message
+// (eg: "ERROR:", "[warn]", "DEBUG ") is removed and used as the record level,
+// otherwise lvl is used. The log flags and prefix must not be changed after
+// the redirection.
+//
+// The returned function restores the previous output of the log package.
+func RedirectStdLogEx(l Logger, lvl Lvl, inferLvl bool) (undo func()) {
+	prev := log.Writer()
+	w := &stdLogWriter{l: l, lvl: lvl, inferLvl: inferLvl, flags: log.Flags(), prefix: log.Prefix()}
+	log.SetOutput(w)
+	return func() {
+		log.SetOutput(prev)
+	}
+}
+
+type stdLogWriter struct {
+	l        Logger
+	lvl      Lvl
+	inferLvl bool
+	flags    int
+	prefix   string
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	lvl, msg, caller := w.parse(string(bytes.TrimRight(p, "\n")))
+	var ctx []interface{}
+	if caller != "" {
+		ctx = append(ctx, CallerCtx(caller))
+	}
+	if l, ok := w.l.(*logger); ok {
+		if l.Enabled(lvl) {
+			l.writeCall(nil, msg, lvl, ctx, stdLogCaller())
+		}
+	} else {
+		logAt(w.l, lvl, msg, ctx...)
+	}
+	return len(p), nil
+}
+
+// stdLogCaller returns the call site of the log package function which wrote
+// the line: the first caller of stdLogWriter.Write outside of the log package,
+// as with the calldepth of 2 used by the log package functions.
+func stdLogCaller() stack.Call {
+	for skip := 2; ; skip++ {
+		c := stack.Caller(skip)
+		f := c.Frame()
+		if f.PC == 0 || !strings.HasPrefix(f.Function, "log.") {
+			return c
+		}
+	}
+}
+
+// parse splits the line written by the log package into the message and the caller.
+func (w *stdLogWriter) parse(s string) (lvl Lvl, msg, caller string) {
+	if w.flags&stdLogMsgPrefix == 0 {
+		s = strings.TrimPrefix(s, w.prefix)
+	}
+	if w.flags&log.Ldate != 0 && len(s) >= len("2009/01/23 ") {
+		s = s[len("2009/01/23 "):]
+	}
+	if w.flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		n := len("01:23:23 ")
+		if w.flags&log.Lmicroseconds != 0 {
+			n += len(".123123")
+		}
+		if len(s) >= n {
+			s = s[n:]
+		}
+	}
+	if w.flags&(log.Lshortfile|log.Llongfile) != 0 {
+		if i := strings.Index(s, ": "); i >= 0 {
+			caller, s = s[:i], s[i+2:]
+		}
+	}
+	if w.flags&stdLogMsgPrefix != 0 {
+		s = strings.TrimPrefix(s, w.prefix)
+	}
+	lvl = w.lvl
+	if w.inferLvl {
+		if l, rest, ok := parseLvlToken(s); ok {
+			lvl, s = l, rest
+		}
+	}
+	return lvl, s, caller
+}
+
+var lvlTokens = map[string]Lvl{
+	"TRACE":    LvlTrace,
+	"DEBUG":    LvlDebug,
+	"INFO":     LvlInfo,
+	"WARN":     LvlWarn,
+	"WARNING":  LvlWarn,
+	"ERR":      LvlError,
+	"ERROR":    LvlError,
+	"CRIT":     LvlCrit,
+	"CRITICAL": LvlCrit,
+	"FATAL":    LvlCrit,
+	"PANIC":    LvlCrit,
+}
+
+// parseLvlToken recognizes a leading level token like "ERROR:", "[warn]" or "INFO ".
+func parseLvlToken(s string) (Lvl, string, bool) {
+	t := s
+	bracket := strings.HasPrefix(t, "[")
+	if bracket {
+		t = t[1:]
+	}
+	i := strings.IndexAny(t, ": ]")
+	if i <= 0 {
+		return 0, s, false
+	}
+	token := strings.ToUpper(t[:i])
+	lvl, ok := lvlTokens[token]
+	// tokens followed by a space must be upper case, to not confuse them with words
+	if !ok || bracket != (t[i] == ']') || t[i] == ' ' && token != t[:i] {
+		return 0, s, false
+	}
+	rest := t[i+1:]
+	if bracket {
+		rest = strings.TrimPrefix(rest, ":")
+	}
+	return lvl, strings.TrimLeft(rest, " "), true
+}
+
+// logAt logs the message with the logger method of the given level.
+func logAt(l Logger, lvl Lvl, msg string, ctx ...interface{}) {
+	switch lvl {
+	case LvlCrit:
+		l.Crit(msg, ctx...)
+	case LvlError:
+		l.Error(msg, ctx...)
+	case LvlWarn:
+		l.Warn(msg, ctx...)
+	case LvlInfo:
+		l.Info(msg, ctx...)
+	case LvlDebug:
+		l.Debug(msg, ctx...)
+	default:
+		l.Trace(msg, ctx...)
+	}
+}
@@ -0,0 +1,70 @@
+package log15
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestRedirectStdLog(t *testing.T) {
+	flags, prefix := log.Flags(), log.Prefix()
+	defer func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+	}()
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+	log.SetPrefix("app: ")
+
+	l, _, r := testLogger()
+	undo := RedirectStdLogEx(l, LvlInfo, true)
+	log.Printf("ERROR: disk %s", "full")
+	if r.Msg != "disk full" || r.Lvl != LvlError {
+		t.Fatalf("wrong record: %+v", r)
+	}
+	if len(r.Ctx) != 1 || !strings.HasPrefix(string(r.Ctx[0].(CallerCtx)), "stdlog_test.go:") {
+		t.Fatalf("expected caller in the record context, got %v", r.Ctx)
+	}
+	if call := fmt.Sprintf("%v", r.Call); call != string(r.Ctx[0].(CallerCtx)) {
+		t.Fatalf("wrong record call site %s, expected %s", call, r.Ctx[0])
+	}
+	log.Print("[warn] slow")
+	if r.Msg != "slow" || r.Lvl != LvlWarn {
+		t.Fatalf("wrong record: %+v", r)
+	}
+	log.Print("Info about the state")
+	if r.Msg != "Info about the state" || r.Lvl != LvlInfo {
+		t.Fatalf("wrong record: %+v", r)
+	}
+	undo()
+
+	log.SetFlags(0)
+	log.SetPrefix("")
+	undo = RedirectStdLog(l, LvlDebug)
+	defer undo()
+	log.Print("ERROR: not inferred")
+	if r.Msg != "ERROR: not inferred" || r.Lvl != LvlDebug || len(r.Ctx) != 0 {
+		t.Fatalf("wrong record: %+v", r)
+	}
+}
+
+func TestParseLvlToken(t *testing.T) {
+	t.Parallel()
+
+	for s, expected := range map[string]string{
+		"ERROR: a":   "a",
+		"error: a":   "a",
+		"[Debug] a":  "a",
+		"[INFO]: a":  "a",
+		"WARNING a":  "a",
+		"warning a":  "",
+		"[ERROR a":   "",
+		"ERRORS: a":  "",
+		"no level a": "",
+	} {
+		_, rest, ok := parseLvlToken(s)
+		if ok != (expected != "") || ok && rest != expected {
+			t.Fatalf("parseLvlToken(%q) = %q, %v", s, rest, ok)
+		}
+	}
+}
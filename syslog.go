@@ -3,8 +3,12 @@
 package log15
 
 import (
+	"crypto/tls"
+	"fmt"
 	"log/syslog"
+	"os"
 	"strings"
+	"time"
 )
 
 // SyslogHandler opens a connection to the system syslog daemon by calling
@@ -21,6 +25,53 @@ func SyslogNetHandler(net, addr string, priority syslog.Priority, tag string, fm
 	return sharedSyslog(fmtr, wr, err)
 }
 
+// SyslogTLSHandler opens a TLS connection to a log daemon and writes all log
+// records to it. The config sets the client certificates, the trusted CAs and
+// the server name. Records are sent in the same format as SyslogNetHandler sends
+// them. To reconnect after network failures, use:
+//
+//     log.ReconnectingNetHandler("tcp", addr, log.SyslogFormat(priority, tag, fmtr),
+//         log.ReconnectConfig{TLSConfig: config})
+//
+func SyslogTLSHandler(addr string, config *tls.Config, priority syslog.Priority, tag string, fmtr Format) (Handler, error) {
+	return TLSNetHandler("tcp", addr, config, SyslogFormat(priority, tag, fmtr))
+}
+
+// SyslogFormat returns a format which wraps records formatted with fmtr into
+// syslog lines, the same as the log/syslog package writes them to remote daemons:
+//
+//     <PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG
+//
+// The facility is taken from priority, the severity from the record level.
+// If tag is empty, the program name is used.
+func SyslogFormat(priority syslog.Priority, tag string, fmtr Format) Format {
+	if tag == "" {
+		tag = os.Args[0]
+	}
+	hostname, _ := os.Hostname()
+	pid := os.Getpid()
+	facility := priority &^ 7
+	return FormatFunc(func(r *Record) []byte {
+		msg := strings.TrimSpace(string(fmtr.Format(r)))
+		return []byte(fmt.Sprintf("<%d>%s %s %s[%d]: %s\n",
+			facility|syslogSeverity(r.Lvl), r.Time.Format(time.RFC3339), hostname, tag, pid, msg))
+	})
+}
+
+func syslogSeverity(lvl Lvl) syslog.Priority {
+	switch lvl {
+	case LvlCrit:
+		return syslog.LOG_CRIT
+	case LvlError:
+		return syslog.LOG_ERR
+	case LvlWarn:
+		return syslog.LOG_WARNING
+	case LvlInfo:
+		return syslog.LOG_INFO
+	}
+	return syslog.LOG_DEBUG
+}
+
 func sharedSyslog(fmtr Format, sysWr *syslog.Writer, err error) (Handler, error) {
 	if err != nil {
 		return nil, err
@@ -53,3 +104,7 @@ func (m muster) SyslogHandler(priority syslog.Priority, tag string, fmtr Format)
 func (m muster) SyslogNetHandler(net, addr string, priority syslog.Priority, tag string, fmtr Format) Handler {
 	return must(SyslogNetHandler(net, addr, priority, tag, fmtr))
 }
+
+func (m muster) SyslogTLSHandler(addr string, config *tls.Config, priority syslog.Priority, tag string, fmtr Format) Handler {
+	return must(SyslogTLSHandler(addr, config, priority, tag, fmtr))
+}
@@ -0,0 +1,40 @@
+// +build !windows,!plan9
+
+package log15
+
+import (
+	"log/syslog"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestSyslogTLSHandler(t *testing.T) {
+	t.Parallel()
+
+	certs := newTestCerts(t)
+	ln := certs.listen(t, "127.0.0.1:0")
+	defer ln.Close()
+	lines := readLines(ln, 0)
+
+	h, err := SyslogTLSHandler(ln.Addr().String(), certs.clientConfig(), syslog.LOG_LOCAL0|syslog.LOG_INFO, "app", msgFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer TryClose(h)
+	h.Log(&Record{Time: time.Now(), Lvl: LvlError, Msg: "disk full"})
+	h.Log(&Record{Time: time.Now(), Lvl: LvlDebug, Msg: "retry"})
+
+	re := regexp.MustCompile(`^<(\d+)>\d{4}-\d\d-\d\dT\S+ \S+ app\[\d+\]: (.*)$`)
+	for _, e := range []struct{ pri, msg string }{{"131", "disk full"}, {"135", "retry"}} {
+		select {
+		case l := <-lines:
+			m := re.FindStringSubmatch(l)
+			if m == nil || m[1] != e.pri || m[2] != e.msg {
+				t.Fatalf("wrong syslog line: %q", l)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out")
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package log15
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottleConfig configures ThrottleHandler.
+type ThrottleConfig struct {
+	// Keys are context keys which identify a record, in addition to its level
+	// and message. Records with different values of these keys are not collapsed
+	// and have separate rate limits.
+	Keys []string
+	// Window collapses repeated records: only the first record in the window is
+	// passed and the repeats are counted. Zero disables the deduplication.
+	Window time.Duration
+	// Rate is the number of records per second allowed for each identity.
+	// Zero disables the rate limit.
+	Rate float64
+	// Burst is the maximum number of records passed at once under the rate limit.
+	// Default: 1.
+	Burst int
+}
+
+// ThrottleHandler returns a handler which protects h from floods of the same record.
+// Records are identified by their level, message and the values of c.Keys.
+//
+// Repeats of a record within c.Window are collapsed: the first one is passed
+// immediately, the rest are counted and, when the window ends, the last of them is
+// passed with the count in the "repeated" context key:
+//
+//     lvl=eror msg="db down" err="connection refused" repeated=4211
+//
+// Records passed to h are also limited by a token bucket of c.Rate records per
+// second with c.Burst size, per identity. When the limit lifts, a notice with
+// the number of suppressed records is logged:
+//
+//     lvl=eror msg="records suppressed by rate limit" suppressed_msg="db down" suppressed=120
+//
+// Close passes the pending collapsed records and closes h.
+func ThrottleHandler(c ThrottleConfig, h Handler) *Throttle {
+	t := newThrottle(c, h, time.Now)
+	interval := c.Window
+	if interval <= 0 {
+		interval = time.Second
+	}
+	t.wg.Add(1)
+	go t.loop(interval)
+	return t
+}
+
+// newThrottle creates the Throttle without starting the background goroutine.
+func newThrottle(c ThrottleConfig, h Handler, now func() time.Time) *Throttle {
+	if c.Burst < 1 {
+		c.Burst = 1
+	}
+	return &Throttle{
+		c:       c,
+		h:       h,
+		now:     now,
+		dedups:  make(map[throttleKey]*dedupState),
+		buckets: make(map[throttleKey]*bucketState),
+		done:    make(chan struct{}),
+	}
+}
+
+// Throttle is the Handler returned by ThrottleHandler.
+type Throttle struct {
+	c    ThrottleConfig
+	h    Handler
+	now  func() time.Time
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex // guards the fields below
+	dedups  map[throttleKey]*dedupState
+	buckets map[throttleKey]*bucketState
+	closed  bool
+}
+
+type throttleKey struct {
+	lvl  Lvl
+	msg  string
+	vals string
+}
+
+type dedupState struct {
+	start   time.Time
+	repeats int
+	last    *Record
+}
+
+type bucketState struct {
+	tokens     float64
+	updated    time.Time
+	suppressed int
+	r          *Record // the last suppressed record
+}
+
+func (t *Throttle) key(r *Record) throttleKey {
+	k := throttleKey{lvl: r.Lvl, msg: r.Msg}
+	if len(t.c.Keys) == 0 {
+		return k
+	}
+	vals := make([]string, len(t.c.Keys))
+	for i := 0; i+1 < len(r.Ctx); i += 2 {
+		if name, ok := r.Ctx[i].(string); ok {
+			for j, key := range t.c.Keys {
+				if name == key {
+					vals[j] = fmt.Sprint(r.Ctx[i+1])
+				}
+			}
+		}
+	}
+	k.vals = strings.Join(vals, "\x00")
+	return k
+}
+
+// Log implements Handler interface.
+func (t *Throttle) Log(r *Record) error {
+	k := t.key(r)
+	now := t.now()
+	var out []*Record
+	t.mu.Lock()
+	if t.c.Window > 0 {
+		d := t.dedups[k]
+		if d != nil && now.Sub(d.start) < t.c.Window {
+			d.repeats++
+			d.last = r
+			t.mu.Unlock()
+			return nil
+		}
+		if d != nil {
+			out = t.collapsed(out, k, d, now)
+		}
+		t.dedups[k] = &dedupState{start: now}
+	}
+	out = t.limit(out, k, r, now)
+	t.mu.Unlock()
+	return t.log(out)
+}
+
+// collapsed appends the record which summarizes the repeats of d, if any,
+// subject to the rate limit. It must be called with t.mu held.
+func (t *Throttle) collapsed(out []*Record, k throttleKey, d *dedupState, now time.Time) []*Record {
+	if d.repeats == 0 {
+		return out
+	}
+	r := *d.last
+	r.Ctx = append(r.Ctx[:len(r.Ctx):len(r.Ctx)], "repeated", d.repeats)
+	d.repeats = 0
+	return t.limit(out, k, &r, now)
+}
+
+// limit appends r if the rate limit allows it. It must be called with t.mu held.
+func (t *Throttle) limit(out []*Record, k throttleKey, r *Record, now time.Time) []*Record {
+	if t.c.Rate <= 0 {
+		return append(out, r)
+	}
+	b := t.buckets[k]
+	if b == nil {
+		b = &bucketState{tokens: float64(t.c.Burst), updated: now}
+		t.buckets[k] = b
+	}
+	t.refill(b, now)
+	if b.tokens < 1 {
+		b.suppressed++
+		b.r = r
+		return out
+	}
+	b.tokens--
+	out = t.lifted(out, k, b)
+	return append(out, r)
+}
+
+func (t *Throttle) refill(b *bucketState, now time.Time) {
+	b.tokens += now.Sub(b.updated).Seconds() * t.c.Rate
+	if max := float64(t.c.Burst); b.tokens > max {
+		b.tokens = max
+	}
+	b.updated = now
+}
+
+// lifted appends the notice about records suppressed by the bucket b.
+func (t *Throttle) lifted(out []*Record, k throttleKey, b *bucketState) []*Record {
+	if b.suppressed == 0 {
+		return out
+	}
+	out = append(out, &Record{
+		Time:     t.now(),
+		Lvl:      k.lvl,
+		Msg:      "records suppressed by rate limit",
+		Ctx:      []interface{}{"suppressed_msg", k.msg, "suppressed", b.suppressed},
+		KeyNames: b.r.KeyNames,
+		Name:     b.r.Name,
+	})
+	b.suppressed = 0
+	b.r = nil
+	return out
+}
+
+func (t *Throttle) log(out []*Record) error {
+	var err error
+	for _, r := range out {
+		if lerr := t.h.Log(r); err == nil {
+			err = lerr
+		}
+	}
+	return err
+}
+
+func (t *Throttle) loop(interval time.Duration) {
+	defer t.wg.Done()
+	tk := time.NewTicker(interval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			t.tick(false)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// tick passes the collapsed records of the windows which ended, notices of lifted
+// rate limits and forgets idle records. With all set it ends all windows.
+func (t *Throttle) tick(all bool) {
+	now := t.now()
+	var out []*Record
+	t.mu.Lock()
+	for k, d := range t.dedups {
+		if all || now.Sub(d.start) >= t.c.Window {
+			out = t.collapsed(out, k, d, now)
+			delete(t.dedups, k)
+		}
+	}
+	for k, b := range t.buckets {
+		t.refill(b, now)
+		if b.suppressed > 0 && b.tokens >= 1 {
+			b.tokens--
+			out = t.lifted(out, k, b)
+		}
+		if b.suppressed == 0 && b.tokens >= float64(t.c.Burst) {
+			delete(t.buckets, k)
+		}
+	}
+	t.mu.Unlock()
+	t.log(out)
+}
+
+// Enabled implements LvlChecker interface.
+func (t *Throttle) Enabled(lvl Lvl) bool {
+	return LvlEnabled(t.h, lvl)
+}
+
+// Flush flushes the wrapped handler.
+func (t *Throttle) Flush() error {
+	return TryFlush(t.h)
+}
+
+// Close passes the pending collapsed records, stops the background goroutine
+// and closes the wrapped handler. Records still suppressed by the rate limit
+// are reported with a notice.
+func (t *Throttle) Close() error {
+	t.mu.Lock()
+	closed := t.closed
+	t.closed = true
+	t.mu.Unlock()
+	if closed {
+		return nil
+	}
+	close(t.done)
+	t.wg.Wait()
+	t.tick(true)
+	var out []*Record
+	t.mu.Lock()
+	for k, b := range t.buckets {
+		out = t.lifted(out, k, b)
+	}
+	t.mu.Unlock()
+	t.log(out)
+	return TryClose(t.h)
+}
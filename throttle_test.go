@@ -0,0 +1,90 @@
+package log15
+
+import (
+	"testing"
+	"time"
+)
+
+func throttleTest(c ThrottleConfig) (*Throttle, Logger, *[]*Record, *time.Time) {
+	var recs []*Record
+	h := FuncHandler(func(r *Record) error {
+		recs = append(recs, r)
+		return nil
+	})
+	now := time.Unix(1000, 0)
+	th := newThrottle(c, h, func() time.Time { return now })
+	l := New()
+	l.SetHandler(th)
+	return th, l, &recs, &now
+}
+
+func TestThrottleDedup(t *testing.T) {
+	t.Parallel()
+
+	th, l, recs, now := throttleTest(ThrottleConfig{Keys: []string{"db"}, Window: time.Hour})
+	defer th.Close()
+	for i := 0; i < 5; i++ {
+		l.Error("down", "db", "a", "i", i)
+	}
+	l.Error("down", "db", "b")
+	if len(*recs) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(*recs))
+	}
+
+	*now = now.Add(time.Hour)
+	th.tick(false)
+	if len(*recs) != 3 {
+		t.Fatalf("expected the collapsed record, got %d records", len(*recs))
+	}
+	r := (*recs)[2]
+	if r.Msg != "down" || len(r.Ctx) != 6 || r.Ctx[3] != 4 || r.Ctx[4] != "repeated" || r.Ctx[5] != 4 {
+		t.Fatalf("wrong collapsed record: %s %v", r.Msg, r.Ctx)
+	}
+
+	l.Error("down", "db", "a")
+	if len(*recs) != 4 {
+		t.Fatalf("expected a new window")
+	}
+}
+
+func TestThrottleDedupClose(t *testing.T) {
+	t.Parallel()
+
+	th, l, recs, _ := throttleTest(ThrottleConfig{Window: time.Hour})
+	l.Info("x")
+	l.Info("x")
+	l.Info("x")
+	th.Close()
+	if len(*recs) != 2 || (*recs)[1].Ctx[1] != 2 {
+		t.Fatalf("expected the collapsed record on Close, got %d records", len(*recs))
+	}
+}
+
+func TestThrottleRate(t *testing.T) {
+	t.Parallel()
+
+	th, l, recs, now := throttleTest(ThrottleConfig{Rate: 1, Burst: 2})
+	defer th.Close()
+	for i := 0; i < 10; i++ {
+		l.Warn("flood")
+	}
+	if len(*recs) != 2 {
+		t.Fatalf("expected the burst of 2 records, got %d", len(*recs))
+	}
+
+	*now = now.Add(time.Second)
+	th.tick(false)
+	if len(*recs) != 3 {
+		t.Fatalf("expected the suppressed notice, got %d records", len(*recs))
+	}
+	r := (*recs)[2]
+	if r.Msg != "records suppressed by rate limit" || r.Lvl != LvlWarn || r.Ctx[1] != "flood" || r.Ctx[3] != 8 {
+		t.Fatalf("wrong notice: %s %v", r.Msg, r.Ctx)
+	}
+
+	*now = now.Add(time.Second)
+	l.Warn("flood")
+	if len(*recs) != 4 || (*recs)[3].Msg != "flood" {
+		t.Fatalf("expected the record to pass after the limit lifted")
+	}
+}
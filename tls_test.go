@@ -0,0 +1,160 @@
+package log15
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testCerts holds a CA and the server and client certificates signed by it.
+type testCerts struct {
+	pool   *x509.CertPool
+	server tls.Certificate
+	client tls.Certificate
+}
+
+func newTestCerts(t *testing.T) *testCerts {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "log15 test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func(serial int64, usage x509.ExtKeyUsage) tls.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "localhost"},
+			DNSNames:     []string{"localhost"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+	c := &testCerts{pool: x509.NewCertPool()}
+	c.pool.AddCert(ca)
+	c.server = issue(2, x509.ExtKeyUsageServerAuth)
+	c.client = issue(3, x509.ExtKeyUsageClientAuth)
+	return c
+}
+
+// listen starts a TLS listener which requires client certificates.
+func (c *testCerts) listen(t *testing.T, addr string) net.Listener {
+	ln, err := tls.Listen("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{c.server},
+		ClientCAs:    c.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func (c *testCerts) clientConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{c.client},
+		RootCAs:      c.pool,
+		ServerName:   "localhost",
+	}
+}
+
+func TestTLSNetHandler(t *testing.T) {
+	t.Parallel()
+
+	certs := newTestCerts(t)
+	ln := certs.listen(t, "127.0.0.1:0")
+	defer ln.Close()
+	lines := readLines(ln, 0)
+
+	h, err := TLSNetHandler("tcp", ln.Addr().String(), certs.clientConfig(), msgFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logN(h, 0, 3)
+	expectLines(t, lines, "0", "1", "2")
+	if err := TryClose(h); err != nil {
+		t.Fatal(err)
+	}
+
+	// the server doesn't trust the client without its certificate
+	config := certs.clientConfig()
+	config.Certificates = nil
+	if h, err := TLSNetHandler("tcp", ln.Addr().String(), config, msgFormat); err == nil {
+		// TLS 1.3 reports the rejected client certificate on the first read or write
+		logN(h, 0, 1)
+		TryClose(h)
+		select {
+		case l := <-lines:
+			t.Fatalf("unexpected line from an untrusted client: %q", l)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// the client doesn't trust a server with an unknown CA
+	config = certs.clientConfig()
+	config.RootCAs = x509.NewCertPool()
+	if _, err := TLSNetHandler("tcp", ln.Addr().String(), config, msgFormat); err == nil {
+		t.Fatalf("expected certificate verification error")
+	}
+}
+
+func TestReconnectingNetTLS(t *testing.T) {
+	t.Parallel()
+
+	certs := newTestCerts(t)
+	addr := freeAddr(t)
+	onError, closes := peerCloses()
+	h, err := ReconnectingNetHandler("tcp", addr, msgFormat, ReconnectConfig{
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+		TLSConfig:  certs.clientConfig(),
+		OnError:    onError,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	logN(h, 0, 2)
+
+	ln := certs.listen(t, addr)
+	defer ln.Close()
+	lines := readLines(ln, 2)
+	expectLines(t, lines, "0", "1")
+	// the server closed the connection after 2 lines
+	expectPeerClose(t, closes)
+	logN(h, 2, 4)
+	expectLines(t, lines, "2", "3")
+}
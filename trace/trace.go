@@ -0,0 +1,189 @@
+// Package trace correlates log records with distributed tracing spans.
+// It understands W3C Trace Context (https://www.w3.org/TR/trace-context/)
+// and doesn't depend on any tracing SDK. Adapt your tracer span to the Span
+// interface to get log records attached to spans as events.
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robert-zaremba/log15"
+)
+
+// Keys added to the log record context.
+const (
+	TraceIDKey    = "trace_id"
+	SpanIDKey     = "span_id"
+	TraceFlagsKey = "trace_flags"
+)
+
+// SpanContext identifies a span as defined by W3C Trace Context.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// IsValid reports whether both trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// IsSampled reports whether the sampled flag is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&1 == 1
+}
+
+// Traceparent formats sc as the value of the `traceparent` header.
+func (sc SpanContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" +
+		hex.EncodeToString(sc.SpanID[:]) + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent parses the value of the `traceparent` header.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, errors.New("Wrong traceparent format, expected version-traceid-spanid-flags")
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, errors.New("Wrong traceparent format, too many fields for version 00")
+	}
+	var flags [1]byte
+	if err := decodeHex(sc.TraceID[:], parts[1], "trace id"); err != nil {
+		return sc, err
+	}
+	if err := decodeHex(sc.SpanID[:], parts[2], "span id"); err != nil {
+		return sc, err
+	}
+	if err := decodeHex(flags[:], parts[3], "trace flags"); err != nil {
+		return sc, err
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, errors.New("Wrong traceparent, trace id and span id can't be zero")
+	}
+	return sc, nil
+}
+
+func decodeHex(dst []byte, s, what string) error {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return errors.New("Wrong traceparent " + what + " length or case")
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// Span is the part of a tracing span used to attach log records.
+type Span interface {
+	SpanContext() SpanContext
+	AddEvent(name string, t time.Time, attrs []interface{})
+}
+
+type spanContextKey struct{}
+type spanKey struct{}
+
+// ContextWithSpanContext returns a copy of c which carries sc.
+func ContextWithSpanContext(c context.Context, sc SpanContext) context.Context {
+	return context.WithValue(c, spanContextKey{}, sc)
+}
+
+// ContextWithSpan returns a copy of c which carries the span s.
+func ContextWithSpan(c context.Context, s Span) context.Context {
+	return context.WithValue(c, spanKey{}, s)
+}
+
+// SpanFromContext returns the span stored in c or nil.
+func SpanFromContext(c context.Context) Span {
+	s, _ := c.Value(spanKey{}).(Span)
+	return s
+}
+
+// SpanContextFromContext returns the span context of the span stored in c
+// or the span context stored with ContextWithSpanContext.
+func SpanContextFromContext(c context.Context) (SpanContext, bool) {
+	if s := SpanFromContext(c); s != nil {
+		sc := s.SpanContext()
+		return sc, sc.IsValid()
+	}
+	sc, ok := c.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// Extractor is a log15.ContextExtractor which adds trace_id, span_id and trace_flags
+// of the span found in the context.
+func Extractor(c context.Context) []interface{} {
+	sc, ok := SpanContextFromContext(c)
+	if !ok {
+		return nil
+	}
+	return []interface{}{
+		TraceIDKey, hex.EncodeToString(sc.TraceID[:]),
+		SpanIDKey, hex.EncodeToString(sc.SpanID[:]),
+		TraceFlagsKey, hex.EncodeToString([]byte{sc.Flags}),
+	}
+}
+
+// Handler returns a log15.Handler which adds the trace correlation keys (see Extractor)
+// to records logged with a context. If spanEvents is true, the record is also added
+// as an event to the span stored in the record context.
+func Handler(h log15.Handler, spanEvents bool) log15.Handler {
+	return log15.PassFuncHandler(func(r *log15.Record) error {
+		if r.Context == nil {
+			return h.Log(r)
+		}
+		if spanEvents {
+			if s := SpanFromContext(r.Context); s != nil {
+				attrs := make([]interface{}, 0, len(r.Ctx)+4)
+				attrs = append(attrs, "log.severity", r.Lvl.String(), "log.message", r.Msg)
+				s.AddEvent("log", r.Time, append(attrs, r.Ctx...))
+			}
+		}
+		r.Ctx = append(r.Ctx, Extractor(r.Context)...)
+		return h.Log(r)
+	}, h)
+}
+
+// Event is a span event captured by RecordingSpan.
+type Event struct {
+	Name  string
+	Time  time.Time
+	Attrs []interface{}
+}
+
+// RecordingSpan is an in-memory Span, useful for tests.
+type RecordingSpan struct {
+	sc     SpanContext
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewRecordingSpan creates a RecordingSpan with the given span context.
+func NewRecordingSpan(sc SpanContext) *RecordingSpan {
+	return &RecordingSpan{sc: sc}
+}
+
+// SpanContext implements Span interface.
+func (s *RecordingSpan) SpanContext() SpanContext {
+	return s.sc
+}
+
+// AddEvent implements Span interface.
+func (s *RecordingSpan) AddEvent(name string, t time.Time, attrs []interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, Event{name, t, attrs})
+}
+
+// Events returns the recorded events.
+func (s *RecordingSpan) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
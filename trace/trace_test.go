@@ -0,0 +1,92 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	log "github.com/robert-zaremba/log15"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func testHandler() (log.Handler, *log.Record) {
+	rec := new(log.Record)
+	return log.FuncHandler(func(r *log.Record) error {
+		*rec = *r
+		return nil
+	}), rec
+}
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.IsSampled() || sc.Traceparent() != traceparent {
+		t.Fatalf("wrong span context: %+v", sc)
+	}
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(s); err == nil {
+			t.Fatalf("expected error for %q", s)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	sc, _ := ParseTraceparent(traceparent)
+	span := NewRecordingSpan(sc)
+	h, r := testHandler()
+	l := log.New()
+	l.SetHandler(Handler(h, true))
+
+	l.InfoCtx(ContextWithSpan(context.Background(), span), "traced", "x", 1)
+	expected := []interface{}{"x", 1,
+		TraceIDKey, "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanIDKey, "00f067aa0ba902b7",
+		TraceFlagsKey, "01"}
+	if len(r.Ctx) != len(expected) {
+		t.Fatalf("got ctx %v, expected %v", r.Ctx, expected)
+	}
+	for i := range expected {
+		if r.Ctx[i] != expected[i] {
+			t.Fatalf("got ctx %v, expected %v", r.Ctx, expected)
+		}
+	}
+	events := span.Events()
+	if len(events) != 1 || events[0].Attrs[3] != "traced" {
+		t.Fatalf("expected the record as span event, got %+v", events)
+	}
+
+	l.InfoCtx(ContextWithSpanContext(context.Background(), sc), "remote parent")
+	if len(r.Ctx) != 6 || r.Ctx[1] != expected[3] {
+		t.Fatalf("expected trace keys from the span context, got %v", r.Ctx)
+	}
+	if len(span.Events()) != 1 {
+		t.Fatalf("expected no span event without span")
+	}
+
+	l.Info("no context")
+	if len(r.Ctx) != 0 {
+		t.Fatalf("expected no trace keys, got %v", r.Ctx)
+	}
+}
+
+func TestHandlerEnabled(t *testing.T) {
+	t.Parallel()
+
+	h, _ := testHandler()
+	th := Handler(log.LvlFilterHandler(log.LvlWarn, h), false)
+	if log.LvlEnabled(th, log.LvlInfo) || !log.LvlEnabled(th, log.LvlWarn) {
+		t.Fatalf("expected Enabled to be passed to the wrapped handler")
+	}
+}